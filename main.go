@@ -2,9 +2,11 @@ package main
 
 import (
 	"AureoHMSBE/routes"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
@@ -12,6 +14,10 @@ import (
 )
 
 func main() {
+	backfillFoodRevenue := flag.String("backfill-food-revenue", "", "rebuild the daily food revenue rollup for START:END (YYYY-MM-DD) and exit")
+	fakeWallet := flag.Bool("fake-wallet", false, "offer the fake wallet provider for trying out wallet payments; never use in production")
+	flag.Parse()
+
 	dsn := "Aenlu:Hninhninlatt21!@tcp(87.106.203.188:3306)/Aureo_Cloud?charset=utf8mb4&parseTime=True&loc=Local"
 	var err error
 	routes.DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
@@ -19,6 +25,10 @@ func main() {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 
+	if err := routes.RunPreSchemaMigrations(); err != nil {
+		log.Fatalf("Failed to run data migrations: %v", err)
+	}
+
 	dbError := routes.DB.AutoMigrate(
 		&routes.Receptionist{},
 		&routes.Admin{},
@@ -26,18 +36,75 @@ func main() {
 		&routes.Reservation{},
 		&routes.Guests{},
 		&routes.FoodOrder{},
+		&routes.FoodOrderItem{},
+		&routes.FoodOrderItemModifier{},
+		&routes.MenuCategory{},
+		&routes.ModifierGroup{},
+		&routes.ModifierOption{},
+		&routes.StockItem{},
+		&routes.RecipeIngredient{},
+		&routes.StockMovement{},
+		&routes.Amenity{},
+		&routes.AmenityCharge{},
+		&routes.Payment{},
+		&routes.PaymentProviderConfig{},
+		&routes.CashierShift{},
+		&routes.ShiftCount{},
+		&routes.Expense{},
+		&routes.TaxSettings{},
+		&routes.TaxRule{},
+		&routes.TaxLine{},
+		&routes.Currency{},
+		&routes.ExchangeRate{},
+		&routes.Account{},
+		&routes.JournalEntry{},
+		&routes.JournalLine{},
+		&routes.RefundSettings{},
+		&routes.RefundRequest{},
 		&routes.Menu{},
 		&routes.Income{},
 		&routes.Staff{},
 		&routes.CleaningRecord{},
 		&routes.DailyFoodRevenue{},
-		&routes.RoomPrices{})
+		&routes.RoomPrices{},
+		&routes.DailyClose{},
+		&routes.RoomCharge{},
+		&routes.GroupReservation{},
+		&routes.GroupRoom{},
+		&routes.GuestProfile{},
+		&routes.LoyaltyTransaction{},
+		&routes.IdentityDocument{},
+		&routes.SchemaMigration{})
 	if dbError != nil {
 		return
 	}
+
+	if err := routes.RunDataMigrations(); err != nil {
+		log.Fatalf("Failed to run data migrations: %v", err)
+	}
 	fmt.Println("Database and tables created successfully")
 
-	router := gin.Default()
+	if *backfillFoodRevenue != "" {
+		start, end, ok := strings.Cut(*backfillFoodRevenue, ":")
+		if !ok {
+			log.Fatal("-backfill-food-revenue needs a range in the form START:END")
+		}
+		if err := routes.BackfillFoodRevenue(start, end); err != nil {
+			log.Fatalf("Failed to backfill food revenue: %v", err)
+		}
+		fmt.Printf("Food revenue rebuilt from %s to %s\n", start, end)
+		return
+	}
+
+	// gin.Default, except that http.ErrAbortHandler is passed on to net/http so a handler can cut off a
+	// response it has already started, as an export does when it fails part way through
+	router := gin.New()
+	router.Use(gin.Logger(), gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
 
 	// Let Nginx handle CORS
 	router.Use(func(c *gin.Context) {
@@ -65,6 +132,15 @@ func main() {
 	router.DELETE("reservations/:id", routes.DeleteReservation)
 	router.PUT("reservations/:id", routes.UpdateReservation)
 
+	// Group reservations
+	router.POST("/group-reservations", routes.CreateGroupReservation)
+	router.GET("/group-reservations/date/:date", routes.GetGroupReservationsByDate)
+	router.GET("/group-reservations/:id", routes.GetGroupReservation)
+	router.GET("/group-reservations/:id/bill", routes.GetGroupBill)
+	router.POST("/group-reservations/:id/checkin", routes.GroupCheckIn)
+	router.POST("/group-reservations/:id/checkout", routes.GroupCheckOut)
+	router.POST("/group-reservations/:id/cancel", routes.CancelGroupReservation)
+
 	//Rooms
 	router.GET("/rooms", routes.GetRooms)
 	router.GET("/rooms/:room", routes.GetRoom)
@@ -75,11 +151,31 @@ func main() {
 	router.POST("/prices", routes.UpdateRoomPrices)
 
 	//Guests
-	router.POST("/create-guest", routes.CreateGuest)
 	router.GET("/guests/current/:roomNumber", routes.GetCurrentGuest)
 	router.GET("/guests/checkouts/today", routes.GetTodayCheckouts)
-	router.PUT("/guests/:id", routes.UpdateGuestInfo)
-	router.PUT("/guests/foodPrice/:id", routes.UpdateGuestFoodPrice)
+	router.GET("/guests/:id/payments", routes.GetGuestPayments)
+	router.GET("/guests/:id/balance", routes.GetGuestBalance)
+	router.GET("/guests/:id/invoice", routes.GetGuestInvoice)
+	router.GET("reservations/:id/payments", routes.GetReservationPayments)
+	router.POST("/payments/webhook/:method", routes.WalletWebhook)
+	if *fakeWallet {
+		routes.EnableFakeWallet()
+		router.POST("/payments/fake/:reference/pay", routes.FakeWalletPay)
+	}
+	router.GET("/amenities", routes.GetAmenities)
+	router.GET("/guests/:id/amenity-charges", routes.GetGuestAmenityCharges)
+	router.GET("/guests/:id/documents", routes.GetGuestDocuments)
+	router.POST("/guests/:id/documents", routes.AddGuestDocument)
+
+	// Guest profiles
+	router.GET("/profiles", routes.SearchProfiles)
+	router.GET("/profiles/:id", routes.GetProfile)
+	router.PUT("/profiles/:id", routes.UpdateProfile)
+
+	// Loyalty
+	router.GET("/loyalty/:profileId", routes.GetLoyaltyAccount)
+	router.GET("/loyalty/:profileId/transactions", routes.GetLoyaltyTransactions)
+	router.POST("/loyalty/:profileId/redeem", routes.RedeemLoyaltyPoints)
 
 	// Food
 	router.POST("/food/order", routes.CreateFoodOrder)
@@ -88,8 +184,7 @@ func main() {
 	router.GET("/food/orders/guest/:guestId", routes.GetFoodOrdersByGuestID)
 	router.GET("/food/revenue/today", routes.GetTodayFoodRevenue)
 	router.GET("/food/revenue/date/:date", routes.GetFoodRevenueByDate)
-	router.PUT("/order/:id", routes.UpdateFoodOrder)
-	router.DELETE("/order/:id", routes.DeleteFoodOrder)
+	router.GET("/food/revenue/by-type/:start/:end", routes.GetFoodRevenueByType)
 
 	router.POST("/food/menu", routes.CreateMenu)
 	router.GET("food/menus", routes.GetMenu)
@@ -98,17 +193,24 @@ func main() {
 	router.GET("food/search", routes.SearchMenu)
 	router.PUT("/menu/:id", routes.UpdateMenu)
 	router.DELETE("/menu/:id", routes.DeleteMenu)
+	router.POST("/menu/:id/photo", routes.UploadMenuPhoto)
+	router.GET("/food/categories", routes.GetMenuCategories)
+	router.POST("/food/categories", routes.CreateMenuCategory)
+	router.PUT("/food/categories/:id", routes.UpdateMenuCategory)
+	router.GET("/menu/:id/modifiers", routes.GetMenuModifiers)
+	router.POST("/menu/:id/modifiers", routes.CreateModifierGroup)
+	router.PUT("/menu/modifiers/:id", routes.UpdateModifierGroup)
+	router.DELETE("/menu/modifiers/:id", routes.DeleteModifierGroup)
+	router.POST("/menu/modifiers/:id/options", routes.CreateModifierOption)
+	router.PUT("/menu/modifier-options/:id", routes.UpdateModifierOption)
+	router.Static("/uploads/menu-photos", "uploads/menu-photos")
 
 	// Income Record
-	router.POST("/income", routes.AddIncome)
 	router.GET("income/today", routes.GetTodayIncome)
 	router.GET("income/date/:date", routes.GetIncomeByDate)
 
 	// Admin protected routes
 	adminProtected := router.Group("/admin")
-
-	// Revenue routes
-	adminProtected.GET("/revenue/date/:date", routes.GetRevenueSummaryByDate)
 	adminProtected.Use(routes.AdminAuthMiddleware())
 	{
 		// Food orders
@@ -119,8 +221,92 @@ func main() {
 		adminProtected.GET("/activity", routes.GetRecentActivity)
 
 		// Revenue data
+		adminProtected.GET("/revenue/date/:date", routes.GetRevenueSummaryByDate)
 		adminProtected.GET("/revenue/summary", routes.GetRevenueSummary)
 		adminProtected.GET("/revenue/range/:start/:end", routes.GetRevenueRange)
+
+		// Night audit
+		adminProtected.POST("/night-audit", routes.RunNightAudit)
+		adminProtected.GET("/business-date", routes.GetBusinessDate)
+		adminProtected.GET("/daily-close/:date", routes.GetDailyClose)
+
+		// Wallet payment providers
+		adminProtected.GET("/payment-providers", routes.GetPaymentProviders)
+		adminProtected.PUT("/payment-providers/:method", routes.UpdatePaymentProvider)
+
+		// Cashier shifts
+		adminProtected.GET("/shifts/date/:date", routes.GetShiftsByDate)
+		adminProtected.GET("/shifts/:id/report", routes.GetShiftReport)
+
+		// Expenses
+		adminProtected.GET("/expenses", routes.GetExpenses)
+		adminProtected.POST("/expenses", routes.CreateExpense)
+		adminProtected.GET("/expenses/:id", routes.GetExpense)
+		adminProtected.PUT("/expenses/:id", routes.UpdateExpense)
+		adminProtected.DELETE("/expenses/:id", routes.DeleteExpense)
+		adminProtected.POST("/expenses/:id/receipt", routes.UploadExpenseReceipt)
+		adminProtected.GET("/expenses/:id/receipt", routes.GetExpenseReceipt)
+
+		// Accounting exports
+		adminProtected.GET("/export/:dataset/:start/:end", routes.ExportData)
+
+		// General ledger
+		adminProtected.GET("/accounts", routes.GetAccounts)
+		adminProtected.POST("/accounts", routes.CreateAccount)
+		adminProtected.PUT("/accounts/:id", routes.UpdateAccount)
+		adminProtected.GET("/accounts/:id/ledger/:start/:end", routes.GetAccountLedger)
+		adminProtected.GET("/journal", routes.GetJournalEntries)
+		adminProtected.POST("/journal", routes.CreateJournalEntry)
+		adminProtected.GET("/ledger/trial-balance/:date", routes.GetTrialBalance)
+		adminProtected.GET("/ledger/profit-loss/:start/:end", routes.GetProfitAndLoss)
+
+		// Refunds and voids
+		adminProtected.GET("/refunds", routes.GetRefundRequests)
+		adminProtected.POST("/refunds/:id/approve", routes.ApproveRefund)
+		adminProtected.POST("/refunds/:id/reject", routes.RejectRefund)
+		adminProtected.GET("/refund-settings", routes.GetRefundSettings)
+		adminProtected.PUT("/refund-settings", routes.UpdateRefundSettings)
+
+		// Service charge and commercial tax
+		adminProtected.GET("/tax-settings", routes.GetTaxSettings)
+		adminProtected.PUT("/tax-settings", routes.UpdateTaxSettings)
+		adminProtected.GET("/tax/report/:month", routes.GetMonthlyTaxReport)
+
+		// Currencies and exchange rates
+		adminProtected.GET("/currencies", routes.GetCurrencies)
+		adminProtected.POST("/currencies", routes.SaveCurrency)
+		adminProtected.GET("/exchange-rates", routes.GetExchangeRates)
+		adminProtected.POST("/exchange-rates", routes.SetExchangeRate)
+		adminProtected.DELETE("/exchange-rates/:id", routes.DeleteExchangeRate)
+
+		// Occupancy, ADR and RevPAR trends
+		adminProtected.GET("/analytics/occupancy/:start/:end", routes.GetOccupancyAnalytics)
+
+		// Food revenue rollup
+		adminProtected.POST("/food-revenue/backfill/:start/:end", routes.RunFoodRevenueBackfill)
+		adminProtected.GET("/food-revenue/check/:start/:end", routes.CheckFoodRevenue)
+
+		// Loyalty reversals
+		adminProtected.POST("/loyalty/reverse/income/:id", routes.ReverseIncomeLoyalty)
+		adminProtected.POST("/loyalty/reverse/stay/:id", routes.ReverseStayLoyalty)
+
+		// Identity document scans are only viewable by admins
+		adminProtected.GET("/documents/:id/scan", routes.GetDocumentScan)
+
+		// Inventory
+		adminProtected.GET("/stock", routes.GetStockItems)
+		adminProtected.POST("/stock", routes.CreateStockItem)
+		adminProtected.GET("/stock/low", routes.GetLowStockReport)
+		adminProtected.PUT("/stock/:id", routes.UpdateStockItem)
+		adminProtected.POST("/stock/:id/in", routes.StockIn)
+		adminProtected.POST("/stock/:id/wastage", routes.RecordWastage)
+		adminProtected.GET("/stock/:id/movements", routes.GetStockMovements)
+		adminProtected.GET("/menu/:id/recipe", routes.GetMenuRecipe)
+		adminProtected.PUT("/menu/:id/recipe", routes.SetMenuRecipe)
+
+		// Minibar and amenity catalogue
+		adminProtected.POST("/amenities", routes.CreateAmenity)
+		adminProtected.PUT("/amenities/:id", routes.UpdateAmenity)
 	}
 
 	// Protected routes group
@@ -129,7 +315,28 @@ func main() {
 
 	// Add your protected routes here
 	protected.GET("/stats", routes.GetDashboardStats)
+	protected.POST("/create-guest", routes.CreateGuest)
+	protected.PUT("/guests/:id", routes.UpdateGuestInfo)
+	protected.PUT("/guests/foodPrice/:id", routes.UpdateGuestFoodPrice)
+	protected.PUT("/order/:id", routes.UpdateFoodOrder)
+	protected.DELETE("/order/:id", routes.DeleteFoodOrder)
 	protected.POST("rooms/assign-staff", routes.AssignStaffToRoom)
+	protected.POST("/documents/:id/scan", routes.UploadDocumentScan)
+	protected.POST("/guests/:id/payments", routes.AddGuestPayment)
+	protected.POST("/reservations/:id/payments", routes.AddReservationPayment)
+	protected.POST("/guests/:id/wallet-payments", routes.StartWalletPayment)
+	protected.GET("/payments/:id/status", routes.GetPaymentStatus)
+	protected.POST("/income", routes.AddIncome)
+	protected.GET("/exchange-rates/current", routes.GetCurrentExchangeRates)
+	protected.POST("/food/walk-in", routes.CreateWalkInOrder)
+	protected.POST("/income/:id/refund", routes.RefundIncome)
+	protected.POST("/income/:id/void", routes.VoidIncome)
+
+	// Cashier shifts
+	protected.POST("/shifts/open", routes.OpenShift)
+	protected.GET("/shifts/current", routes.GetCurrentShift)
+	protected.POST("/shifts/close", routes.CloseShift)
+	protected.GET("/shifts/:id/report", routes.GetShiftReport)
 
 	// Staff protected routes
 	staffRoutes := router.Group("/staff")
@@ -141,6 +348,16 @@ func main() {
 		staffRoutes.POST("/cleaning/complete", routes.CompleteCleaning)
 		staffRoutes.GET("/cleaning/history", routes.GetCleaningHistory)
 		staffRoutes.GET("/list", routes.GetStaffList)
+		staffRoutes.GET("/amenities", routes.GetAmenities)
+		staffRoutes.POST("/rooms/:room/consumption", routes.StaffRoleMiddleware("HOUSEKEEPING"), routes.RecordRoomConsumption)
+	}
+
+	// Kitchen staff routes
+	kitchenRoutes := router.Group("/kitchen")
+	kitchenRoutes.Use(routes.StaffAuthMiddleware(), routes.StaffRoleMiddleware("KITCHEN"))
+	{
+		kitchenRoutes.GET("/queue", routes.GetKitchenQueue)
+		kitchenRoutes.PATCH("/orders/:id/status", routes.UpdateFoodOrderStatus)
 	}
 
 	// Start the server
@@ -3,25 +3,29 @@ package routes
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 )
 
 type RevenueData struct {
-	TotalRevenue      float64   `json:"totalRevenue"`
-	RoomRevenue       float64   `json:"roomRevenue"`
-	RoomCashRevenue   float64   `json:"roomCashRevenue"`
-	RoomOnlineRevenue float64   `json:"roomOnlineRevenue"`
-	FoodRevenue       float64   `json:"foodRevenue"`
-	OtherRevenue      float64   `json:"otherRevenue"`
+	TotalRevenue      Money     `json:"totalRevenue"`
+	RoomRevenue       Money     `json:"roomRevenue"`
+	RoomCashRevenue   Money     `json:"roomCashRevenue"`
+	RoomOnlineRevenue Money     `json:"roomOnlineRevenue"`
+	FoodRevenue       Money     `json:"foodRevenue"`
+	OtherRevenue      Money     `json:"otherRevenue"`
+	Expenses          Money     `json:"expenses"`
+	NetProfit         Money     `json:"netProfit"` // Total revenue less expenses
 	Date              time.Time `json:"date"`
 }
 
 type Activity struct {
 	Type          string    `json:"type"`
 	Message       string    `json:"message"`
-	Amount        float64   `json:"amount"`
+	Amount        Money     `json:"amount"`
 	RoomNumber    int       `json:"roomNumber,omitempty"`
 	GuestID       uint      `json:"guestId,omitempty"`
 	Description   string    `json:"description,omitempty"`
@@ -34,7 +38,8 @@ func GetFoodOrdersByDate(c *gin.Context) {
 	date := c.Param("date")
 	var foodOrders []FoodOrder
 
-	if err := DB.Where("DATE(created_at) = ?", date).
+	if err := DB.Preload("Items.Modifiers").
+		Scopes(createdBetween(date, date)).
 		Order("created_at DESC").
 		Find(&foodOrders).Error; err != nil {
 		fmt.Printf("Error fetching food orders: %v\n", err)
@@ -48,7 +53,8 @@ func GetFoodOrdersByDate(c *gin.Context) {
 func GetAllFoodOrders(c *gin.Context) {
 	var foodOrders []FoodOrder
 
-	if err := DB.Order("created_at DESC").
+	if err := DB.Preload("Items.Modifiers").
+		Order("created_at DESC").
 		Limit(100).
 		Find(&foodOrders).Error; err != nil {
 		fmt.Printf("Error fetching all food orders: %v\n", err)
@@ -73,7 +79,8 @@ func GetRecentActivity(c *gin.Context) {
 	}
 
 	var foodOrders []FoodOrder
-	if err := DB.Order("created_at DESC").
+	if err := DB.Preload("Items.Modifiers").
+		Order("created_at DESC").
 		Limit(50).
 		Find(&foodOrders).Error; err != nil {
 		fmt.Printf("Error fetching recent food orders: %v\n", err)
@@ -82,16 +89,23 @@ func GetRecentActivity(c *gin.Context) {
 	}
 
 	for _, income := range incomes {
+		message := fmt.Sprintf("%s Revenue", income.Type)
+		if income.ReversalOfID != nil {
+			message = fmt.Sprintf("%s %s of income #%d", income.Type, income.RevenueType, *income.ReversalOfID)
+		}
 		activity := Activity{
 			Type:          income.Type,
-			Message:       fmt.Sprintf("%s Revenue", income.Type),
+			Message:       message,
 			Amount:        income.Amount,
 			RoomNumber:    income.RoomNumber,
 			Description:   income.RevenueType,
-			PaymentMethod: income.Guest.PaymentType,
-			RoomType:      income.Guest.RoomType,
+			PaymentMethod: income.PaymentMethod,
 			Timestamp:     income.CreatedAt,
 		}
+		// Walk-in, deposit and reversal incomes have no stay
+		if income.Guest != nil {
+			activity.RoomType = income.Guest.RoomType
+		}
 		if income.GuestID != nil {
 			activity.GuestID = *income.GuestID
 		}
@@ -99,10 +113,14 @@ func GetRecentActivity(c *gin.Context) {
 	}
 
 	for _, order := range foodOrders {
+		label := "Food order"
+		if order.OrderType == "WALK_IN" {
+			label = "Walk-in order"
+		}
 		activities = append(activities, Activity{
 			Type:       "food_order",
-			Message:    fmt.Sprintf("Food order: %s (x%d)", order.FoodName, order.Quantity),
-			Amount:     order.Price * float64(order.Quantity),
+			Message:    fmt.Sprintf("%s: %s", label, foodOrderSummary(order)),
+			Amount:     order.Total,
 			GuestID:    order.GuestID,
 			RoomNumber: int(order.RoomID),
 			Timestamp:  order.CreatedAt,
@@ -153,8 +171,8 @@ func GetRevenueSummaryByDate(c *gin.Context) {
 	}
 
 	// Get room revenue split by payment type
-	var roomCashIncome float64
-	var roomOnlineIncome float64
+	var roomCashIncome Money
+	var roomOnlineIncome Money
 
 	fmt.Printf("[Revenue Debug] Querying room cash revenue for date: %s\n", date)
 	if err := DB.Model(&Income{}).
@@ -179,7 +197,7 @@ func GetRevenueSummaryByDate(c *gin.Context) {
 	fmt.Printf("[Revenue Debug] Room online revenue: %v\n", roomOnlineIncome)
 
 	// Get food revenue
-	var foodIncome float64
+	var foodIncome Money
 	fmt.Printf("[Revenue Debug] Querying food revenue for date: %s\n", date)
 	if err := DB.Model(&Income{}).
 		Where("DATE(created_at) = ? AND type = 'food'", date).
@@ -192,7 +210,7 @@ func GetRevenueSummaryByDate(c *gin.Context) {
 	fmt.Printf("[Revenue Debug] Food revenue: %v\n", foodIncome)
 
 	// Get other revenue
-	var otherIncome float64
+	var otherIncome Money
 	fmt.Printf("[Revenue Debug] Querying other revenue for date: %s\n", date)
 	if err := DB.Model(&Income{}).
 		Where("DATE(created_at) = ? AND type NOT IN ('room', 'food')", date).
@@ -204,6 +222,14 @@ func GetRevenueSummaryByDate(c *gin.Context) {
 	}
 	fmt.Printf("[Revenue Debug] Other revenue: %v\n", otherIncome)
 
+	// Get expenses
+	expenses, err := expensesByDay(DB, date, date)
+	if err != nil {
+		fmt.Printf("[Revenue Debug] Error fetching expenses: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expenses"})
+		return
+	}
+
 	// Get activities for the day
 	fmt.Printf("[Revenue Debug] Querying activities for date: %s\n", date)
 	var incomes []Income
@@ -242,13 +268,17 @@ func GetRevenueSummaryByDate(c *gin.Context) {
 		activities = append(activities, activity)
 	}
 
+	roomIncome := roomCashIncome.Add(roomOnlineIncome)
+	totalIncome := roomIncome.Add(foodIncome).Add(otherIncome)
 	revenue = RevenueData{
-		TotalRevenue:      roomCashIncome + roomOnlineIncome + foodIncome + otherIncome,
-		RoomRevenue:       roomCashIncome + roomOnlineIncome,
+		TotalRevenue:      totalIncome,
+		RoomRevenue:       roomIncome,
 		RoomCashRevenue:   roomCashIncome,
 		RoomOnlineRevenue: roomOnlineIncome,
 		FoodRevenue:       foodIncome,
 		OtherRevenue:      otherIncome,
+		Expenses:          expenses[date],
+		NetProfit:         totalIncome.Sub(expenses[date]),
 		Date:              time.Now(),
 	}
 
@@ -263,40 +293,39 @@ func GetRevenueRange(c *gin.Context) {
 	startDate := c.Param("start")
 	endDate := c.Param("end")
 
-	// Parse the dates and create full day ranges
-	start, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
+	// Validate the dates; the query covers the full days from 00:00:00 to 23:59:59
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start date format"})
 		return
 	}
 
-	end, err := time.Parse("2006-01-02", endDate)
-	if err != nil {
+	if _, err := time.Parse("2006-01-02", endDate); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end date format"})
 		return
 	}
 
-	// Set start to beginning of day (00:00:00) and end to end of day (23:59:59)
-	startDateTime := start.Format("2006-01-02 00:00:00")
-	endDateTime := end.Format("2006-01-02 23:59:59")
+	byCurrency, ok := revenueByCurrency(c)
+	if !ok {
+		return
+	}
 
-	var results []struct {
-		Date         string  `json:"date"`
-		RoomRevenue  float64 `json:"room_revenue"`
-		FoodRevenue  float64 `json:"food_revenue"`
-		OtherRevenue float64 `json:"other_revenue"`
-		TotalRevenue float64 `json:"total_revenue"`
+	type revenueDay struct {
+		Date         string `json:"date"`
+		Currency     string `json:"currency"`
+		RoomRevenue  Money  `json:"room_revenue"`
+		FoodRevenue  Money  `json:"food_revenue"`
+		OtherRevenue Money  `json:"other_revenue"`
+		Refunds      Money  `json:"refunds"` // Refunds and voids, already taken off the other totals
+		TotalRevenue Money  `json:"total_revenue"`
+		Expenses     Money  `json:"expenses"`
+		NetProfit    Money  `json:"net_profit"`
 	}
+	var results []revenueDay
 
 	// Update query to use full day range
-	err = DB.Model(&Income{}).
-		Select(`DATE(created_at) as date,
-			   COALESCE(SUM(CASE WHEN type = 'room' THEN amount ELSE 0 END), 0) as room_revenue,
-			   COALESCE(SUM(CASE WHEN type = 'food' THEN amount ELSE 0 END), 0) as food_revenue,
-			   COALESCE(SUM(CASE WHEN type = 'other' THEN amount ELSE 0 END), 0) as other_revenue,
-			   COALESCE(SUM(amount), 0) as total_revenue`).
-		Where("created_at BETWEEN ? AND ?", startDateTime, endDateTime).
-		Group("DATE(created_at)").
+	err := DB.Model(&Income{}).
+		Select("DATE(created_at) as date, "+revenueColumns(byCurrency)).
+		Scopes(createdBetween(startDate, endDate), groupRevenue(byCurrency, "DATE(created_at)")).
 		Order("date").
 		Scan(&results).Error
 
@@ -306,20 +335,38 @@ func GetRevenueRange(c *gin.Context) {
 		return
 	}
 
+	expenses, err := expensesByDay(DB, startDate, endDate)
+	if err != nil {
+		fmt.Printf("Error getting expenses for revenue range: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch expense data"})
+		return
+	}
+
+	// Take each day's expenses off its kyat revenue, adding days that only had expenses
+	for i := range results {
+		totals := &results[i]
+		labelCurrency(totals.Currency, &totals.RoomRevenue, &totals.FoodRevenue, &totals.OtherRevenue, &totals.Refunds, &totals.TotalRevenue)
+		if results[i].Currency != baseCurrency {
+			continue
+		}
+		day := results[i].Date
+		if len(day) > 10 {
+			day = day[:10]
+		}
+		results[i].Expenses = expenses[day]
+		results[i].NetProfit = results[i].TotalRevenue.Sub(expenses[day])
+		delete(expenses, day)
+	}
+	for day, amount := range expenses {
+		results = append(results, revenueDay{Date: day, Currency: baseCurrency, Expenses: amount, NetProfit: amount.Neg()})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Date < results[j].Date })
+
 	// If no results for the date range, create a zero-value entry
 	if len(results) == 0 {
-		results = append(results, struct {
-			Date         string  `json:"date"`
-			RoomRevenue  float64 `json:"room_revenue"`
-			FoodRevenue  float64 `json:"food_revenue"`
-			OtherRevenue float64 `json:"other_revenue"`
-			TotalRevenue float64 `json:"total_revenue"`
-		}{
-			Date:         startDate,
-			RoomRevenue:  0,
-			FoodRevenue:  0,
-			OtherRevenue: 0,
-			TotalRevenue: 0,
+		results = append(results, revenueDay{
+			Date:     startDate,
+			Currency: baseCurrency,
 		})
 	}
 
@@ -333,23 +380,29 @@ func GetRevenueSummary(c *gin.Context) {
 	startDateTime := today + " 00:00:00"
 	endDateTime := today + " 23:59:59"
 
-	var result struct {
-		TotalRevenue float64 `json:"total_revenue"`
-		RoomRevenue  float64 `json:"room_revenue"`
-		FoodRevenue  float64 `json:"food_revenue"`
-		OtherRevenue float64 `json:"other_revenue"`
+	byCurrency, ok := revenueByCurrency(c)
+	if !ok {
+		return
+	}
+
+	type revenueTotals struct {
+		Currency     string `json:"currency"`
+		TotalRevenue Money  `json:"total_revenue"`
+		RoomRevenue  Money  `json:"room_revenue"`
+		FoodRevenue  Money  `json:"food_revenue"`
+		OtherRevenue Money  `json:"other_revenue"`
+		Refunds      Money  `json:"refunds"` // Refunds and voids, already taken off the other totals
+		Expenses     Money  `json:"expenses"`
+		NetProfit    Money  `json:"net_profit"`
 	}
+	var results []revenueTotals
 
 	// Get all revenue types in a single query
 	err := DB.Model(&Income{}).
-		Select(`
-			COALESCE(SUM(amount), 0) as total_revenue,
-			COALESCE(SUM(CASE WHEN type = 'room' THEN amount ELSE 0 END), 0) as room_revenue,
-			COALESCE(SUM(CASE WHEN type = 'food' THEN amount ELSE 0 END), 0) as food_revenue,
-			COALESCE(SUM(CASE WHEN type = 'other' THEN amount ELSE 0 END), 0) as other_revenue
-		`).
+		Select(revenueColumns(byCurrency)).
 		Where("created_at BETWEEN ? AND ?", startDateTime, endDateTime).
-		Scan(&result).Error
+		Scopes(groupRevenue(byCurrency)).
+		Scan(&results).Error
 
 	if err != nil {
 		fmt.Printf("Error getting revenue summary: %v\n", err)
@@ -357,5 +410,85 @@ func GetRevenueSummary(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	expenses, err := expensesByDay(DB, today, today)
+	if err != nil {
+		fmt.Printf("Error getting expenses for revenue summary: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to get revenue summary"})
+		return
+	}
+	if !byCurrency {
+		result := revenueTotals{Currency: baseCurrency}
+		if len(results) > 0 {
+			result = results[0]
+		}
+		result.Expenses = expenses[today]
+		result.NetProfit = result.TotalRevenue.Sub(result.Expenses)
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// Expenses are paid in kyat, so they only come off the kyat takings
+	found := false
+	for i := range results {
+		totals := &results[i]
+		labelCurrency(totals.Currency, &totals.TotalRevenue, &totals.RoomRevenue, &totals.FoodRevenue, &totals.OtherRevenue, &totals.Refunds)
+		if results[i].Currency == baseCurrency {
+			results[i].Expenses = expenses[today]
+			results[i].NetProfit = results[i].TotalRevenue.Sub(results[i].Expenses)
+			found = true
+		}
+	}
+	if !found && !expenses[today].IsZero() {
+		results = append(results, revenueTotals{Currency: baseCurrency, Expenses: expenses[today], NetProfit: expenses[today].Neg()})
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// revenueByCurrency reads the ?currency option of the revenue reports: MMK, the default, converts
+// everything to kyat at the rate it was posted at, and "original" splits the totals by the currency the
+// money was taken in
+func revenueByCurrency(c *gin.Context) (bool, bool) {
+	switch strings.ToUpper(c.DefaultQuery("currency", baseCurrency)) {
+	case baseCurrency:
+		return false, true
+	case "ORIGINAL":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"message": "Currency must be MMK or original"})
+	return false, false
+}
+
+// revenueColumns totals income by type, in kyat or in the currency it was taken in
+func revenueColumns(byCurrency bool) string {
+	amount, currency := "amount", "'"+baseCurrency+"'"
+	if byCurrency {
+		amount, currency = "original_amount", "currency"
+	}
+	return strings.NewReplacer("{amount}", amount, "{currency}", currency).Replace(`{currency} as currency,
+			COALESCE(SUM({amount}), 0) as total_revenue,
+			COALESCE(SUM(CASE WHEN type = 'room' THEN {amount} ELSE 0 END), 0) as room_revenue,
+			COALESCE(SUM(CASE WHEN type = 'food' THEN {amount} ELSE 0 END), 0) as food_revenue,
+			COALESCE(SUM(CASE WHEN type = 'other' THEN {amount} ELSE 0 END), 0) as other_revenue,
+			COALESCE(SUM(CASE WHEN reversal_of_id IS NOT NULL THEN -{amount} ELSE 0 END), 0) as refunds`)
+}
+
+// groupRevenue groups the revenue totals by the given columns, and by currency when they are split by it
+func groupRevenue(byCurrency bool, columns ...string) func(db *gorm.DB) *gorm.DB {
+	if byCurrency {
+		columns = append(columns, "currency")
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if len(columns) == 0 {
+			return db
+		}
+		return db.Group(strings.Join(columns, ", "))
+	}
+}
+
+// labelCurrency marks totals summed from a column that holds amounts in different currencies with the
+// currency of the row they were grouped into
+func labelCurrency(currency string, amounts ...*Money) {
+	for _, amount := range amounts {
+		*amount = amount.In(currency)
+	}
 }
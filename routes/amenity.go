@@ -0,0 +1,280 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Amenity is a chargeable item left in rooms, such as a minibar drink or a bathrobe
+type Amenity struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	Name        string     `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Category    string     `gorm:"type:enum('MINIBAR','AMENITY');not null;default:'MINIBAR'"`
+	Price       Money      `gorm:"not null;default:0"`
+	StockItemID *uint      `gorm:"null"` // Stock taken out when the amenity is consumed, if tracked
+	StockItem   *StockItem `gorm:"foreignKey:StockItemID"`
+	Active      *bool      `gorm:"not null;default:true"`
+}
+
+// AmenityCharge is one itemized line on a guest's bill; the amount is also added to the guest's ExtraCharges
+type AmenityCharge struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement"`
+	GuestID      int        `gorm:"not null;index"`
+	RoomNumber   int        `gorm:"not null"`
+	AmenityID    uint       `gorm:"not null"`
+	Name         string     `gorm:"not null"` // Copied from the catalogue so later edits don't change past bills
+	UnitPrice    Money      `gorm:"not null"`
+	Quantity     uint       `gorm:"not null"`
+	Amount       Money      `gorm:"not null"`
+	StaffID      *uint      `gorm:"null"`
+	BusinessDate *time.Time `gorm:"type:date"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+}
+
+func (charge *AmenityCharge) BeforeCreate(tx *gorm.DB) error {
+	if charge.BusinessDate == nil {
+		businessDate, err := currentBusinessDate(hookDB(tx))
+		if err != nil {
+			return err
+		}
+		charge.BusinessDate = &businessDate
+	}
+	return checkBusinessDateOpen(hookDB(tx), *charge.BusinessDate)
+}
+
+// postAmenityCharges records consumption against a guest's stay, adds it to their extra charges and takes
+// any tracked items out of stock
+func postAmenityCharges(tx *gorm.DB, guest Guests, staffID *uint, consumed map[uint]uint) ([]AmenityCharge, error) {
+	var charges []AmenityCharge
+	var total Money
+	for amenityID, quantity := range consumed {
+		var amenity Amenity
+		if err := tx.First(&amenity, amenityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("amenity %d not found", amenityID)
+			}
+			return nil, err
+		}
+		if amenity.Active != nil && !*amenity.Active {
+			return nil, fmt.Errorf("%s is no longer offered", amenity.Name)
+		}
+
+		charge := AmenityCharge{
+			GuestID:    guest.ID,
+			RoomNumber: guest.RoomNumber,
+			AmenityID:  amenity.ID,
+			Name:       amenity.Name,
+			UnitPrice:  amenity.Price,
+			Quantity:   quantity,
+			Amount:     amenity.Price.Times(int(quantity)),
+			StaffID:    staffID,
+		}
+		if err := tx.Create(&charge).Error; err != nil {
+			return nil, err
+		}
+		description := fmt.Sprintf("Room %d %s (x%d)", guest.RoomNumber, amenity.Name, quantity)
+		if err := postFolioCharge(tx, "AMENITY_CHARGE", charge.ID, accountOtherRevenue, untaxed(charge.Amount), description); err != nil {
+			return nil, err
+		}
+
+		if amenity.StockItemID != nil {
+			chargeID := charge.ID
+			reason := fmt.Sprintf("Room %d consumption", guest.RoomNumber)
+			if err := adjustStock(tx, StockMovement{
+				StockItemID:     *amenity.StockItemID,
+				Type:            "SALE",
+				Quantity:        -float64(quantity),
+				AmenityChargeID: &chargeID,
+				Reason:          &reason,
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		total = total.Add(charge.Amount)
+		charges = append(charges, charge)
+	}
+
+	result := tx.Model(&Guests{}).
+		Where("id = ? AND status = ?", guest.ID, "ACTIVE").
+		Update("extra_charges", gorm.Expr("extra_charges + ?", total))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrGuestNotActive
+	}
+	return charges, nil
+}
+
+func GetAmenities(c *gin.Context) {
+	query := DB.Order("category, name")
+	if c.Query("all") != "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	var amenities []Amenity
+	if err := query.Find(&amenities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch amenities"})
+		return
+	}
+	c.JSON(http.StatusOK, amenities)
+}
+
+func CreateAmenity(c *gin.Context) {
+	var amenity Amenity
+	if err := c.BindJSON(&amenity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	amenity.Name = strings.TrimSpace(amenity.Name)
+	if amenity.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Amenity name is required"})
+		return
+	}
+	if amenity.Price.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Price can't be negative"})
+		return
+	}
+
+	amenity.ID = 0
+	amenity.StockItem = nil
+	if err := DB.Create(&amenity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create amenity: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, amenity)
+}
+
+func UpdateAmenity(c *gin.Context) {
+	id := c.Param("id")
+
+	var amenity Amenity
+	if err := DB.First(&amenity, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Amenity not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	amenityID := amenity.ID
+	if err := c.BindJSON(&amenity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	amenity.ID = amenityID
+	amenity.StockItem = nil
+
+	if amenity.Price.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Price can't be negative"})
+		return
+	}
+
+	if err := DB.Save(&amenity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, amenity)
+}
+
+// RecordRoomConsumption is used by housekeeping to post minibar and amenity use found while cleaning a room
+func RecordRoomConsumption(c *gin.Context) {
+	staffId := uint(c.GetFloat64("user_id"))
+	if staffId == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	roomNumber, err := strconv.Atoi(c.Param("room"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room number"})
+		return
+	}
+
+	var request struct {
+		Items []struct {
+			AmenityID uint `json:"amenity_id"`
+			Quantity  uint `json:"quantity"`
+		} `json:"items"`
+	}
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if len(request.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No items to record"})
+		return
+	}
+
+	consumed := make(map[uint]uint)
+	for _, item := range request.Items {
+		if item.Quantity == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be at least 1"})
+			return
+		}
+		consumed[item.AmenityID] += item.Quantity
+	}
+
+	tx := DB.Begin()
+
+	var guest Guests
+	if err := tx.Where("room_number = ? AND status = ?", roomNumber, "ACTIVE").First(&guest).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No guest is checked in to this room"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find guest"})
+		}
+		return
+	}
+
+	charges, err := postAmenityCharges(tx, guest, &staffId, consumed)
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, ErrBusinessDateClosed), errors.Is(err, ErrGuestNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Consumption recorded successfully",
+		"charges": charges,
+	})
+}
+
+// GetGuestAmenityCharges lists the itemized amenity charges on a stay
+func GetGuestAmenityCharges(c *gin.Context) {
+	guestID := c.Param("id")
+
+	var charges []AmenityCharge
+	if err := DB.Where("guest_id = ?", guestID).Order("created_at").Find(&charges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch amenity charges"})
+		return
+	}
+
+	var total Money
+	for _, charge := range charges {
+		total = total.Add(charge.Amount)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"charges": charges,
+		"total":   total,
+	})
+}
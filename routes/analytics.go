@@ -0,0 +1,317 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// maxAnalyticsDays is the longest range the occupancy analytics cover in one request
+const maxAnalyticsDays = 731
+
+// bookingTypes are the ways a room can be sold. Rooms have no type of their own, so the booking type is
+// also the room type the analytics break down by.
+var bookingTypes = []string{"FULL-NIGHT", "DAY-CAUTION", "SESSION"}
+
+// occupancyStats are the room figures for a period. Every room can be sold as any booking type, so the
+// room nights available are the whole hotel's even in the breakdown by booking type.
+type occupancyStats struct {
+	RoomNightsSold      int     `json:"roomNightsSold"`
+	RoomNightsAvailable int     `json:"roomNightsAvailable"`
+	OccupancyRate       float64 `json:"occupancyRate"` // Percent of the room nights available that were sold
+	RoomRevenue         Money   `json:"roomRevenue"`
+	ADR                 Money   `json:"adr"`    // Average daily rate: room revenue per room night sold
+	RevPAR              Money   `json:"revpar"` // Room revenue per room night available
+}
+
+// finish works out the rates once the counts and revenue are in
+func (stats *occupancyStats) finish() {
+	if stats.RoomNightsAvailable > 0 {
+		stats.OccupancyRate = float64(stats.RoomNightsSold) / float64(stats.RoomNightsAvailable) * 100
+	}
+	stats.ADR = stats.RoomRevenue.Div(stats.RoomNightsSold)
+	stats.RevPAR = stats.RoomRevenue.Div(stats.RoomNightsAvailable)
+}
+
+type occupancyPeriod struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	occupancyStats
+	ByType map[string]*occupancyStats `json:"byType"`
+}
+
+func newOccupancyPeriod(start string) *occupancyPeriod {
+	period := &occupancyPeriod{Start: start, End: start, ByType: make(map[string]*occupancyStats, len(bookingTypes))}
+	for _, bookingType := range bookingTypes {
+		period.ByType[bookingType] = &occupancyStats{}
+	}
+	return period
+}
+
+// add counts a day of available rooms towards the period
+func (period *occupancyPeriod) add(day string, totalRooms int) {
+	period.End = day
+	period.RoomNightsAvailable += totalRooms
+	for _, stats := range period.ByType {
+		stats.RoomNightsAvailable += totalRooms
+	}
+}
+
+func (period *occupancyPeriod) finish() {
+	period.occupancyStats.finish()
+	for _, stats := range period.ByType {
+		stats.finish()
+	}
+}
+
+// periodStart is the first day of the day, week or month a day falls in; weeks start on Monday
+func periodStart(day time.Time, period string) time.Time {
+	switch period {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// occupiedRoom is a room sold on a day, once for the hotel as a whole (with no booking type) and once for
+// each booking type it was sold as, so two sessions in the same room count as one room night sold
+type occupiedRoom struct {
+	Day         string
+	Room        int
+	BookingType string
+}
+
+// roomRevenue is room revenue booked to a day, for the booking type of the stay it came from; income taken
+// with no stay has no booking type and only counts towards the hotel as a whole
+type roomRevenue struct {
+	Date     string
+	RoomType string
+	Revenue  Money
+}
+
+// occupancyReport lays the range out in periods, cut to the range at either end, and notes which one each
+// day falls in
+type occupancyReport struct {
+	start    time.Time
+	end      time.Time
+	periods  []*occupancyPeriod
+	periodOf map[string]*occupancyPeriod
+	total    *occupancyPeriod
+}
+
+func newOccupancyReport(startDate, endDate time.Time, period string, totalRooms int) *occupancyReport {
+	report := &occupancyReport{
+		start:    startDate,
+		end:      endDate,
+		periodOf: make(map[string]*occupancyPeriod),
+		total:    newOccupancyPeriod(startDate.Format("2006-01-02")),
+	}
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		bucket := periodStart(day, period)
+		if bucket.Before(startDate) {
+			bucket = startDate
+		}
+		current, ok := report.periodOf[bucket.Format("2006-01-02")]
+		if !ok {
+			current = newOccupancyPeriod(bucket.Format("2006-01-02"))
+			report.periods = append(report.periods, current)
+		}
+		current.add(key, totalRooms)
+		report.periodOf[key] = current
+		report.total.add(key, totalRooms)
+	}
+	return report
+}
+
+// addStays counts the room nights the stays sold within the range. A stay sells its room on every night
+// from check-in up to the night before check-out; a day or session stay that checks out the day it checks
+// in sells it for that day.
+func (report *occupancyReport) addStays(stays []Guests) {
+	sold := make(map[occupiedRoom]bool)
+	for _, stay := range stays {
+		first, last := dateOnly(stay.CheckinDate), dateOnly(stay.CheckoutDate).AddDate(0, 0, -1)
+		if last.Before(first) {
+			last = first
+		}
+		if first.Before(report.start) {
+			first = report.start
+		}
+		if last.After(report.end) {
+			last = report.end
+		}
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			key := day.Format("2006-01-02")
+			current := report.periodOf[key]
+			for _, room := range []occupiedRoom{{key, stay.RoomNumber, ""}, {key, stay.RoomNumber, stay.RoomType}} {
+				if sold[room] {
+					continue
+				}
+				sold[room] = true
+				for _, into := range []*occupancyPeriod{current, report.total} {
+					if room.BookingType == "" {
+						into.RoomNightsSold++
+					} else if stats, ok := into.ByType[room.BookingType]; ok {
+						stats.RoomNightsSold++
+					}
+				}
+			}
+		}
+	}
+}
+
+// addRevenue books room revenue to the periods its days fall in
+func (report *occupancyReport) addRevenue(revenue []roomRevenue) {
+	for _, row := range revenue {
+		current, ok := report.periodOf[row.Date]
+		if !ok {
+			continue
+		}
+		for _, into := range []*occupancyPeriod{current, report.total} {
+			into.RoomRevenue = into.RoomRevenue.Add(row.Revenue)
+			if stats, ok := into.ByType[row.RoomType]; ok {
+				stats.RoomRevenue = stats.RoomRevenue.Add(row.Revenue)
+			}
+		}
+	}
+}
+
+func (report *occupancyReport) finish() {
+	for _, current := range report.periods {
+		current.finish()
+	}
+	report.total.finish()
+}
+
+// folioRoomRevenue is the room charges on the stays' folios that the night audit didn't post: the charges
+// taken at check-in, which are all a day or session stay between two audits ever has, and any later
+// adjustments. They are booked to the day the stay checked in. audited is the room charges the night audit
+// posted to each stay.
+func folioRoomRevenue(stays []Guests, audited map[int]Money) []roomRevenue {
+	var revenue []roomRevenue
+	for _, stay := range stays {
+		amount := stay.RoomCharges.Sub(audited[stay.ID])
+		if amount.IsZero() {
+			continue
+		}
+		revenue = append(revenue, roomRevenue{dateOnly(stay.CheckinDate).Format("2006-01-02"), stay.RoomType, amount})
+	}
+	return revenue
+}
+
+// GetOccupancyAnalytics reports occupancy, ADR and RevPAR between two dates for each day, week or month
+// (?period=day|week|month), for the whole hotel and by booking type, for the dashboard trend charts.
+//
+// Room nights sold come from the stays, as addStays counts them. Room revenue is what the stays were
+// charged for their rooms: the room charges the night audit posted, on their business dates, and the rest
+// of each stay's folio room charges on the day it checked in. Room income taken with no folio room charges
+// behind it, such as for stays from before the folio was kept, counts on its business date, net of
+// refunds. The rooms available are the hotel's current rooms.
+func GetOccupancyAnalytics(c *gin.Context) {
+	start, end, ok := parseLedgerPeriod(c)
+	if !ok {
+		return
+	}
+	period := c.DefaultQuery("period", "day")
+	if period != "day" && period != "week" && period != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Period must be day, week or month"})
+		return
+	}
+	startDate, _ := time.Parse("2006-01-02", start)
+	endDate, _ := time.Parse("2006-01-02", end)
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must not be before the start date"})
+		return
+	}
+	if endDate.Sub(startDate).Hours()/24 >= maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("The range can be at most %d days", maxAnalyticsDays)})
+		return
+	}
+
+	var totalRooms int64
+	if err := DB.Model(&Rooms{}).Count(&totalRooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count rooms"})
+		return
+	}
+	report := newOccupancyReport(startDate, endDate, period, int(totalRooms))
+
+	var stays []Guests
+	if err := DB.Select("id, room_type, room_number, checkin_date, checkout_date, room_charges").
+		Where("checkin_date < ? AND checkout_date >= ?", endDate.AddDate(0, 0, 1).Format("2006-01-02"), start).
+		Find(&stays).Error; err != nil {
+		fmt.Printf("Error fetching stays for occupancy analytics: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch stays"})
+		return
+	}
+	report.addStays(stays)
+
+	var nightly []roomRevenue
+	if err := DB.Model(&RoomCharge{}).
+		Select("DATE_FORMAT(business_date, '%Y-%m-%d') as date, room_type, COALESCE(SUM(amount), 0) as revenue").
+		Where("business_date BETWEEN ? AND ?", start, end).
+		Group("business_date, room_type").
+		Scan(&nightly).Error; err != nil {
+		fmt.Printf("Error fetching room charges for occupancy analytics: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch room revenue"})
+		return
+	}
+	report.addRevenue(nightly)
+
+	var checkedIn []Guests
+	var checkedInIDs []int
+	for _, stay := range stays {
+		if day := dateOnly(stay.CheckinDate); !day.Before(startDate) && !day.After(endDate) {
+			checkedIn = append(checkedIn, stay)
+			checkedInIDs = append(checkedInIDs, stay.ID)
+		}
+	}
+	audited := make(map[int]Money, len(checkedIn))
+	if len(checkedInIDs) > 0 {
+		var rows []struct {
+			GuestID int
+			Amount  Money
+		}
+		if err := DB.Model(&RoomCharge{}).
+			Select("guest_id, COALESCE(SUM(amount), 0) as amount").
+			Where("guest_id IN ?", checkedInIDs).
+			Group("guest_id").
+			Scan(&rows).Error; err != nil {
+			fmt.Printf("Error fetching room charges for occupancy analytics: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch room revenue"})
+			return
+		}
+		for _, row := range rows {
+			audited[row.GuestID] = row.Amount
+		}
+	}
+	report.addRevenue(folioRoomRevenue(checkedIn, audited))
+
+	var unbilled []roomRevenue
+	if err := DB.Table("incomes").
+		Select(`DATE_FORMAT(COALESCE(incomes.business_date, DATE(incomes.created_at)), '%Y-%m-%d') as date,
+			COALESCE(guests.room_type, '') as room_type, COALESCE(SUM(incomes.amount), 0) as revenue`).
+		Joins("LEFT JOIN guests ON guests.id = incomes.guest_id").
+		Where("incomes.type = ? AND COALESCE(incomes.business_date, DATE(incomes.created_at)) BETWEEN ? AND ?", "room", start, end).
+		Where("guests.id IS NULL OR guests.room_charges = 0").
+		Group("date, guests.room_type").
+		Scan(&unbilled).Error; err != nil {
+		fmt.Printf("Error fetching room income for occupancy analytics: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch room revenue"})
+		return
+	}
+	report.addRevenue(unbilled)
+
+	report.finish()
+
+	c.JSON(http.StatusOK, gin.H{
+		"start":      start,
+		"end":        end,
+		"period":     period,
+		"totalRooms": totalRooms,
+		"periods":    report.periods,
+		"total":      report.total,
+	})
+}
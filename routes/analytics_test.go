@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOccupancyReportSameDaySession checks a session stay that checks in and out between two night audits
+// sells a room night and brings in the room charges taken at check-in, alongside a full night stay whose
+// room was charged by the night audit
+func TestOccupancyReportSameDaySession(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	stays := []Guests{
+		{ID: 1, RoomNumber: 101, RoomType: "SESSION", CheckinDate: day.Add(10 * time.Hour), CheckoutDate: day.Add(13 * time.Hour), RoomCharges: kyat(20000)},
+		{ID: 2, RoomNumber: 102, RoomType: "FULL-NIGHT", CheckinDate: day.Add(14 * time.Hour), CheckoutDate: day.AddDate(0, 0, 1).Add(12 * time.Hour), RoomCharges: kyat(50000)},
+	}
+	// The night audit charged the full night stay's room; the session stay was gone before it ran
+	audited := map[int]Money{2: kyat(50000)}
+	nightly := []roomRevenue{{"2026-03-02", "FULL-NIGHT", kyat(50000)}}
+
+	report := newOccupancyReport(day, day, "day", 10)
+	report.addStays(stays)
+	report.addRevenue(nightly)
+	report.addRevenue(folioRoomRevenue(stays, audited))
+	report.finish()
+
+	if len(report.periods) != 1 {
+		t.Fatalf("periods = %d; want 1", len(report.periods))
+	}
+	session := report.total.ByType["SESSION"]
+	if session.RoomNightsSold != 1 || session.RoomRevenue.Minor != kyat(20000).Minor {
+		t.Errorf("session stays sold %d room nights for %v; want 1 for 20000.00 MMK", session.RoomNightsSold, session.RoomRevenue)
+	}
+	if session.ADR.Minor != kyat(20000).Minor || session.RevPAR.Minor != kyat(2000).Minor {
+		t.Errorf("session ADR %v, RevPAR %v; want 20000.00 and 2000.00 MMK", session.ADR, session.RevPAR)
+	}
+
+	fullNight := report.total.ByType["FULL-NIGHT"]
+	if fullNight.RoomNightsSold != 1 || fullNight.RoomRevenue.Minor != kyat(50000).Minor {
+		t.Errorf("full night stays sold %d room nights for %v; want 1 for 50000.00 MMK", fullNight.RoomNightsSold, fullNight.RoomRevenue)
+	}
+
+	if report.total.RoomNightsSold != 2 || report.total.RoomRevenue.Minor != kyat(70000).Minor {
+		t.Errorf("hotel sold %d room nights for %v; want 2 for 70000.00 MMK", report.total.RoomNightsSold, report.total.RoomRevenue)
+	}
+	if report.total.ADR.Minor != kyat(35000).Minor || report.total.OccupancyRate != 20 {
+		t.Errorf("hotel ADR %v, occupancy %v%%; want 35000.00 MMK and 20%%", report.total.ADR, report.total.OccupancyRate)
+	}
+}
+
+// TestFolioRoomRevenue checks check-in charges and adjustments the night audit didn't post are booked to
+// the check-in day, and nothing is counted twice for a stay the audit charged in full
+func TestFolioRoomRevenue(t *testing.T) {
+	checkin := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	stays := []Guests{
+		{ID: 1, RoomType: "FULL-NIGHT", CheckinDate: checkin, RoomCharges: kyat(60000)},
+		{ID: 2, RoomType: "FULL-NIGHT", CheckinDate: checkin, RoomCharges: kyat(50000)},
+		{ID: 3, RoomType: "DAY-CAUTION", CheckinDate: checkin, RoomCharges: kyat(30000)},
+	}
+	audited := map[int]Money{1: kyat(50000), 2: kyat(50000)}
+
+	revenue := folioRoomRevenue(stays, audited)
+	if len(revenue) != 2 {
+		t.Fatalf("folioRoomRevenue = %v; want the first and third stays", revenue)
+	}
+	want := []roomRevenue{{"2026-03-02", "FULL-NIGHT", kyat(10000)}, {"2026-03-02", "DAY-CAUTION", kyat(30000)}}
+	for i, row := range revenue {
+		if row.Date != want[i].Date || row.RoomType != want[i].RoomType || row.Revenue.Minor != want[i].Revenue.Minor {
+			t.Errorf("revenue[%d] = %+v; want %+v", i, row, want[i])
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package routes
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoOpenShift is returned when cash is taken by a receptionist who hasn't opened a shift
+var ErrNoOpenShift = errors.New("no open cashier shift")
+
+// CashierShift is one receptionist's turn on the till, from opening with a float to counting the drawer
+type CashierShift struct {
+	ID             uint         `gorm:"primaryKey;autoIncrement"`
+	ReceptionistID int          `gorm:"not null;index"`
+	Status         string       `gorm:"type:enum('OPEN','CLOSED');not null;default:'OPEN'"`
+	OpeningFloat   Money        `gorm:"not null;default:0"`
+	ExpectedCash   *Money       `gorm:"null"` // Float plus cash taken, worked out at close
+	CountedCash    *Money       `gorm:"null"`
+	Variance       *Money       `gorm:"null"` // Counted minus expected; negative means the drawer is short
+	Notes          *string      `gorm:"type:text;null"`
+	OpenedAt       time.Time    `gorm:"type:datetime;not null"`
+	ClosedAt       *time.Time   `gorm:"type:datetime"`
+	Counts         []ShiftCount `gorm:"foreignKey:ShiftID"`
+}
+
+// ShiftCount is the expected and counted takings for one payment method when a shift closes
+type ShiftCount struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement"`
+	ShiftID  uint   `gorm:"not null;index"`
+	Method   string `gorm:"type:varchar(50);not null"`
+	Expected Money  `gorm:"not null"`
+	Counted  Money  `gorm:"not null"`
+	Variance Money  `gorm:"not null"`
+}
+
+// openShift returns the receptionist's open shift, or nil if they don't have one
+func openShift(tx *gorm.DB, receptionistID int) (*CashierShift, error) {
+	var shift CashierShift
+	err := tx.Where("receptionist_id = ? AND status = ?", receptionistID, "OPEN").First(&shift).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// attributeIncome records who took an income and on which shift. Cash has to go through an open shift so
+// the drawer can be reconciled, so cash nobody signed in took is refused; other methods are attributed to
+// the shift when there is one.
+func attributeIncome(tx *gorm.DB, income *Income, receptionistID *int) error {
+	if receptionistID == nil {
+		if income.PaymentMethod == "CASH" {
+			return ErrNoOpenShift
+		}
+		return nil
+	}
+	income.ReceptionistID = receptionistID
+
+	shift, err := openShift(tx, *receptionistID)
+	if err != nil {
+		return err
+	}
+	if shift == nil {
+		if income.PaymentMethod == "CASH" {
+			return ErrNoOpenShift
+		}
+		return nil
+	}
+	income.ShiftID = &shift.ID
+	return nil
+}
+
+// shiftTakings totals a shift's income by payment method. Money taken in a foreign currency is counted
+// separately in that currency, under keys like "CASH USD", since that is what is in the drawer.
+func shiftTakings(tx *gorm.DB, shiftID uint) (map[string]Money, error) {
+	var rows []struct {
+		PaymentMethod  string
+		Currency       string
+		Amount         Money
+		OriginalAmount Money
+	}
+	if err := tx.Model(&Income{}).
+		Select("payment_method, currency, COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(original_amount), 0) as original_amount").
+		Where("shift_id = ?", shiftID).
+		Group("payment_method, currency").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	takings := make(map[string]Money)
+	for _, row := range rows {
+		if row.Currency == "" || row.Currency == baseCurrency {
+			takings[row.PaymentMethod] = takings[row.PaymentMethod].Add(row.Amount)
+		} else {
+			key := row.PaymentMethod + " " + row.Currency
+			takings[key] = takings[key].Add(row.OriginalAmount.In(row.Currency))
+		}
+	}
+	return takings, nil
+}
+
+// shiftReport lays out a shift's expected takings per method, with the counts once it is closed
+func shiftReport(tx *gorm.DB, shift CashierShift) (gin.H, error) {
+	takings, err := shiftTakings(tx, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type methodLine struct {
+		Method   string `json:"method"`
+		Taken    Money  `json:"taken"`
+		Expected Money  `json:"expected"`
+		Counted  *Money `json:"counted"`
+		Variance *Money `json:"variance"`
+	}
+	counted := make(map[string]ShiftCount)
+	for _, count := range shift.Counts {
+		counted[count.Method] = count
+	}
+
+	methods := []string{"CASH"}
+	for method := range takings {
+		if method != "CASH" {
+			methods = append(methods, method)
+		}
+	}
+	for method := range counted {
+		if _, ok := takings[method]; !ok && method != "CASH" {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods[1:])
+
+	lines := []methodLine{}
+	var totalTaken Money
+	for _, method := range methods {
+		line := methodLine{Method: method, Taken: takings[method], Expected: takings[method]}
+		if method == "CASH" {
+			line.Expected = line.Expected.Add(shift.OpeningFloat)
+		}
+		if count, ok := counted[method]; ok {
+			// A closed shift keeps what was expected when the drawer was counted, even if its takings are
+			// changed afterwards
+			line.Expected = count.Expected
+			line.Counted = &count.Counted
+			line.Variance = &count.Variance
+		}
+		if !strings.Contains(method, " ") {
+			totalTaken = totalTaken.Add(line.Taken) // Foreign currency lines are left out of the kyat total
+		}
+		lines = append(lines, line)
+	}
+
+	return gin.H{
+		"shift":      shift,
+		"methods":    lines,
+		"totalTaken": totalTaken,
+	}, nil
+}
+
+func OpenShift(c *gin.Context) {
+	receptionist := receptionistID(c)
+	if receptionist == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	var request struct {
+		OpeningFloat Money
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if request.OpeningFloat.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Opening float can't be negative"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	existing, err := openShift(tx, *receptionist)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check for an open shift"})
+		return
+	}
+	if existing != nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"message": "You already have an open shift", "shift": existing})
+		return
+	}
+
+	shift := CashierShift{
+		ReceptionistID: *receptionist,
+		Status:         "OPEN",
+		OpeningFloat:   request.OpeningFloat,
+		OpenedAt:       time.Now().UTC(),
+	}
+	if err := tx.Create(&shift).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to open shift"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Shift opened successfully",
+		"shift":   shift,
+	})
+}
+
+// GetCurrentShift returns the signed-in receptionist's open shift with its takings so far
+func GetCurrentShift(c *gin.Context) {
+	receptionist := receptionistID(c)
+	if receptionist == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	shift, err := openShift(DB, *receptionist)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch shift"})
+		return
+	}
+	if shift == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No open shift"})
+		return
+	}
+
+	report, err := shiftReport(DB, *shift)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build shift report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CloseShift counts the drawer and closes the signed-in receptionist's shift. Cash must be counted; other
+// methods can be counted against their wallet or card statements, and are taken as matching if left out.
+func CloseShift(c *gin.Context) {
+	receptionist := receptionistID(c)
+	if receptionist == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	var request struct {
+		CountedCash *Money
+		Counted     map[string]Money // Other payment methods, by method
+		Notes       *string
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if request.CountedCash == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Counted cash is required"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	shift, err := openShift(tx, *receptionist)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch shift"})
+		return
+	}
+	if shift == nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"message": "No open shift"})
+		return
+	}
+
+	takings, err := shiftTakings(tx, shift.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to total shift takings"})
+		return
+	}
+
+	counted := map[string]Money{"CASH": *request.CountedCash}
+	for method, amount := range request.Counted {
+		if method != "CASH" {
+			counted[method] = amount
+		}
+	}
+	for method, taken := range takings {
+		if _, ok := counted[method]; !ok {
+			counted[method] = taken
+		}
+	}
+
+	for method, amount := range counted {
+		expected := takings[method]
+		if method == "CASH" {
+			expected = expected.Add(shift.OpeningFloat)
+		}
+		count := ShiftCount{
+			ShiftID:  shift.ID,
+			Method:   method,
+			Expected: expected,
+			Counted:  amount,
+			Variance: amount.Sub(expected),
+		}
+		if err := tx.Create(&count).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save shift counts"})
+			return
+		}
+		shift.Counts = append(shift.Counts, count)
+
+		if method == "CASH" {
+			shift.ExpectedCash = &count.Expected
+			shift.CountedCash = &count.Counted
+			shift.Variance = &count.Variance
+		}
+	}
+
+	now := time.Now().UTC()
+	shift.Status = "CLOSED"
+	shift.ClosedAt = &now
+	shift.Notes = request.Notes
+	if err := tx.Omit("Counts").Save(shift).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to close shift"})
+		return
+	}
+
+	report, err := shiftReport(tx, *shift)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build shift report"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, report)
+}
+
+func GetShiftReport(c *gin.Context) {
+	id := c.Param("id")
+
+	var shift CashierShift
+	if err := DB.Preload("Counts").First(&shift, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Shift not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch shift"})
+		return
+	}
+
+	report, err := shiftReport(DB, shift)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build shift report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetShiftsByDate lists the shifts opened on a date for the admin dashboard
+func GetShiftsByDate(c *gin.Context) {
+	date := c.Param("date")
+
+	var shifts []CashierShift
+	if err := DB.Preload("Counts").
+		Where("DATE(opened_at) = ?", date).
+		Order("opened_at").
+		Find(&shifts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch shifts"})
+		return
+	}
+	c.JSON(http.StatusOK, shifts)
+}
@@ -0,0 +1,217 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// baseCurrency is the currency the books are kept in; every amount is also stored as its MMK equivalent
+const baseCurrency = "MMK"
+
+// ErrNoExchangeRate is returned when a foreign currency amount has no exchange rate in effect to convert it
+var ErrNoExchangeRate = errors.New("no exchange rate in effect")
+
+// Currency is a currency the hotel accepts
+type Currency struct {
+	Code   string `json:"code" gorm:"primaryKey;type:varchar(3)"`
+	Name   string `json:"name" gorm:"not null"`
+	Active *bool  `json:"active" gorm:"not null;default:true"`
+}
+
+// ExchangeRate is how many kyat one unit of a currency is worth from a date until the next rate for it
+type ExchangeRate struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Currency      string    `json:"currency" gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_currency_date"`
+	Rate          float64   `json:"rate" gorm:"not null"` // MMK per unit
+	EffectiveFrom time.Time `json:"effectiveFrom" gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_currency_date"`
+	SetBy         *int      `json:"setBy" gorm:"null"` // Admin who entered the rate
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// defaultCurrencies are the currencies the hotel starts out accepting
+var defaultCurrencies = []Currency{
+	{Code: baseCurrency, Name: "Myanmar kyat"},
+	{Code: "USD", Name: "US dollar"},
+}
+
+// exchangeRateOn returns the rate in effect for a currency on a date
+func exchangeRateOn(tx *gorm.DB, currency string, date time.Time) (float64, error) {
+	if currency == baseCurrency {
+		return 1, nil
+	}
+	var rate ExchangeRate
+	err := tx.Where("currency = ? AND effective_from <= ?", currency, dateOnly(date).Format("2006-01-02")).
+		Order("effective_from DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%w for %s on %s", ErrNoExchangeRate, currency, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+// convertToMMK converts an amount taken in a currency to kyat at the rate in effect on the date, rounded
+// to the whole kyat, and returns the rate used
+func convertToMMK(tx *gorm.DB, amount Money, date time.Time) (Money, float64, error) {
+	rate, err := exchangeRateOn(tx, amount.CurrencyCode(), date)
+	if err != nil {
+		return Money{}, 0, err
+	}
+	return amount.Mul(rate).In(baseCurrency).Round(), rate, nil
+}
+
+// checkCurrency fills in the base currency when none is given and makes sure the currency is accepted
+func checkCurrency(tx *gorm.DB, currency *string) error {
+	*currency = strings.ToUpper(strings.TrimSpace(*currency))
+	if *currency == "" || *currency == baseCurrency {
+		*currency = baseCurrency
+		return nil
+	}
+	var accepted Currency
+	if err := tx.First(&accepted, "code = ?", *currency).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("currency %s is not accepted", *currency)
+		}
+		return err
+	}
+	if accepted.Active != nil && !*accepted.Active {
+		return fmt.Errorf("currency %s is no longer accepted", *currency)
+	}
+	return nil
+}
+
+func GetCurrencies(c *gin.Context) {
+	var currencies []Currency
+	if err := DB.Order("code").Find(&currencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch currencies"})
+		return
+	}
+	c.JSON(http.StatusOK, currencies)
+}
+
+// SaveCurrency adds a currency or updates its name and whether it is accepted
+func SaveCurrency(c *gin.Context) {
+	var currency Currency
+	if err := c.ShouldBindJSON(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	currency.Code = strings.ToUpper(strings.TrimSpace(currency.Code))
+	if len(currency.Code) != 3 || strings.TrimSpace(currency.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A three-letter code and a name are required"})
+		return
+	}
+	if currency.Code == baseCurrency && currency.Active != nil && !*currency.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The base currency can't be deactivated"})
+		return
+	}
+
+	if err := DB.Save(&currency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save currency"})
+		return
+	}
+	c.JSON(http.StatusOK, currency)
+}
+
+// GetExchangeRates lists the rate history, optionally for one currency
+func GetExchangeRates(c *gin.Context) {
+	query := DB.Order("currency, effective_from DESC")
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(currency))
+	}
+
+	var rates []ExchangeRate
+	if err := query.Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch exchange rates"})
+		return
+	}
+	c.JSON(http.StatusOK, rates)
+}
+
+// GetCurrentExchangeRates returns today's rate for every accepted foreign currency, for the front desk
+func GetCurrentExchangeRates(c *gin.Context) {
+	var currencies []Currency
+	if err := DB.Where("code <> ? AND active = ?", baseCurrency, true).Order("code").Find(&currencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch currencies"})
+		return
+	}
+
+	rates := []gin.H{}
+	for _, currency := range currencies {
+		rate, err := exchangeRateOn(DB, currency.Code, time.Now())
+		if errors.Is(err, ErrNoExchangeRate) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch exchange rates"})
+			return
+		}
+		rates = append(rates, gin.H{"currency": currency.Code, "name": currency.Name, "rate": rate})
+	}
+	c.JSON(http.StatusOK, rates)
+}
+
+// SetExchangeRate enters the rate for a currency from a date. Amounts already posted keep the rate they
+// were converted at.
+func SetExchangeRate(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	var request struct {
+		Currency      string  `json:"currency"`
+		Rate          float64 `json:"rate"`
+		EffectiveFrom string  `json:"effectiveFrom"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := checkCurrency(DB, &request.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if request.Currency == baseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The base currency doesn't need an exchange rate"})
+		return
+	}
+	if request.Rate <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Rate must be greater than zero"})
+		return
+	}
+	effectiveFrom, err := time.Parse("2006-01-02", request.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid effective date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	rate := ExchangeRate{Currency: request.Currency, EffectiveFrom: effectiveFrom}
+	DB.Where("currency = ? AND effective_from = ?", rate.Currency, effectiveFrom.Format("2006-01-02")).First(&rate)
+	rate.Rate = request.Rate
+	rate.SetBy = &adminID
+	if err := DB.Save(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save exchange rate"})
+		return
+	}
+	c.JSON(http.StatusOK, rate)
+}
+
+func DeleteExchangeRate(c *gin.Context) {
+	id := c.Param("id")
+
+	result := DB.Delete(&ExchangeRate{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete exchange rate"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Exchange rate not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
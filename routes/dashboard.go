@@ -15,14 +15,14 @@ type Rooms struct {
 }
 
 type roomData struct {
-	AvailableRooms int     `json:"availableRooms"`
-	TotalRooms     int     `json:"totalRooms"`
-	FullNight      int     `json:"fullNight"`
-	DayCaution     int     `json:"dayCaution"`
-	Session        int     `json:"session"`
-	Housekeeping   int     `json:"housekeeping"`
-	Maintenance    int     `json:"maintenance"`
-	FoodRevenue    float64 `json:"foodRevenue"`
+	AvailableRooms int   `json:"availableRooms"`
+	TotalRooms     int   `json:"totalRooms"`
+	FullNight      int   `json:"fullNight"`
+	DayCaution     int   `json:"dayCaution"`
+	Session        int   `json:"session"`
+	Housekeeping   int   `json:"housekeeping"`
+	Maintenance    int   `json:"maintenance"`
+	FoodRevenue    Money `json:"foodRevenue"`
 }
 
 func GetDashboardStats(c *gin.Context) {
@@ -0,0 +1,319 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const expenseReceiptSubdir = "expense-receipts"
+
+// expenseCategories and expensePaymentMethods are the values the expenses table allows
+var expenseCategories = map[string]bool{
+	"UTILITIES":   true,
+	"SALARIES":    true,
+	"SUPPLIES":    true,
+	"MAINTENANCE": true,
+	"FOOD_STOCK":  true,
+	"OTHER":       true,
+}
+
+var expensePaymentMethods = map[string]bool{
+	"KPAY":    true,
+	"AYAPAY":  true,
+	"WAVEPAY": true,
+	"CASH":    true,
+	"BANK":    true,
+}
+
+type Expense struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	Category      string    `gorm:"type:enum('UTILITIES','SALARIES','SUPPLIES','MAINTENANCE','FOOD_STOCK','OTHER');not null"`
+	Amount        Money     `gorm:"not null"`
+	PaymentMethod string    `gorm:"type:enum('KPAY','AYAPAY','WAVEPAY','CASH','BANK');not null;default:'CASH'"`
+	Vendor        *string   `gorm:"null"`
+	Description   *string   `gorm:"type:text;null"`
+	ExpenseDate   time.Time `gorm:"type:date;not null;index"`
+	ReceiptPath   *string   `gorm:"null" json:"-"`
+	HasReceipt    bool      `gorm:"-"`
+	ApprovedBy    *int      `gorm:"null"` // Admin who signed off the expense
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+func (expense *Expense) AfterFind(tx *gorm.DB) error {
+	expense.HasReceipt = expense.ReceiptPath != nil
+	return nil
+}
+
+// validateExpense checks the fields a client can set on an expense
+func validateExpense(expense *Expense) error {
+	if expense.Amount.Sign() <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if expense.Category == "" {
+		return errors.New("category is required")
+	}
+	if !expenseCategories[expense.Category] {
+		return errors.New("category must be one of UTILITIES, SALARIES, SUPPLIES, MAINTENANCE, FOOD_STOCK or OTHER")
+	}
+	if expense.PaymentMethod == "" {
+		expense.PaymentMethod = "CASH"
+	}
+	if !expensePaymentMethods[expense.PaymentMethod] {
+		return errors.New("payment method must be one of CASH, KPAY, AYAPAY, WAVEPAY or BANK")
+	}
+	if expense.ExpenseDate.IsZero() {
+		expense.ExpenseDate = time.Now().UTC()
+	}
+	if expense.Vendor != nil {
+		vendor := strings.TrimSpace(*expense.Vendor)
+		expense.Vendor = &vendor
+	}
+	return nil
+}
+
+// expensesByDay totals the expenses dated between start and end, inclusive, keyed by YYYY-MM-DD
+func expensesByDay(db *gorm.DB, start, end string) (map[string]Money, error) {
+	var rows []struct {
+		Date   string
+		Amount Money
+	}
+	if err := db.Model(&Expense{}).
+		Select("DATE_FORMAT(expense_date, '%Y-%m-%d') as date, COALESCE(SUM(amount), 0) as amount").
+		Where("expense_date BETWEEN ? AND ?", start, end).
+		Group("expense_date").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]Money, len(rows))
+	for _, row := range rows {
+		totals[row.Date] = row.Amount
+	}
+	return totals, nil
+}
+
+func GetExpenses(c *gin.Context) {
+	query := DB.Order("expense_date DESC, id DESC")
+	if start := c.Query("start"); start != "" {
+		query = query.Where("expense_date >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("expense_date <= ?", end)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var expenses []Expense
+	if err := query.Find(&expenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch expenses"})
+		return
+	}
+
+	var total Money
+	for _, expense := range expenses {
+		total = total.Add(expense.Amount)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"expenses": expenses,
+		"total":    total,
+	})
+}
+
+func GetExpense(c *gin.Context) {
+	id := c.Param("id")
+
+	var expense Expense
+	if err := DB.First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, expense)
+}
+
+func CreateExpense(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	var expense Expense
+	if err := c.BindJSON(&expense); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := validateExpense(&expense); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	expense.ID = 0
+	expense.ReceiptPath = nil
+	expense.ApprovedBy = &adminID
+
+	tx := DB.Begin()
+	if err := tx.Create(&expense).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create expense: " + err.Error()})
+		return
+	}
+	if err := postExpenseJournal(tx, expense); err != nil {
+		tx.Rollback()
+		fmt.Printf("Error posting expense to the ledger: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post expense to the ledger"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, expense)
+}
+
+func UpdateExpense(c *gin.Context) {
+	id := c.Param("id")
+	adminID := c.GetInt("user_id")
+
+	var expense Expense
+	if err := DB.First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	previous := expense
+	expenseID, receiptPath := expense.ID, expense.ReceiptPath
+	if err := c.BindJSON(&expense); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	expense.ID, expense.ReceiptPath = expenseID, receiptPath
+
+	if err := validateExpense(&expense); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Whoever changes an expense signs off the new figures
+	expense.ApprovedBy = &adminID
+
+	tx := DB.Begin()
+	if err := tx.Save(&expense).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// The old figures are reversed on their own date and the new ones posted in full
+	if err := reverseExpenseJournal(tx, previous); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post expense to the ledger"})
+		return
+	}
+	if err := postExpenseJournal(tx, expense); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post expense to the ledger"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, expense)
+}
+
+func DeleteExpense(c *gin.Context) {
+	id := c.Param("id")
+
+	var expense Expense
+	if err := DB.First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := DB.Begin()
+	if err := tx.Delete(&expense).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete expense"})
+		return
+	}
+	if err := reverseExpenseJournal(tx, expense); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post expense to the ledger"})
+		return
+	}
+	tx.Commit()
+
+	if expense.ReceiptPath != nil {
+		os.Remove(*expense.ReceiptPath)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Expense deleted successfully"})
+}
+
+func UploadExpenseReceipt(c *gin.Context) {
+	id := c.Param("id")
+
+	var expense Expense
+	if err := DB.First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	path, err := saveUpload(c, "receipt", expenseReceiptSubdir, fmt.Sprintf("expense-%d", expense.ID), ".jpg", ".jpeg", ".png", ".pdf")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	previous := expense.ReceiptPath
+	if err := DB.Model(&expense).Update("receipt_path", path).Error; err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save receipt"})
+		return
+	}
+	if previous != nil {
+		os.Remove(*previous)
+	}
+
+	expense.HasReceipt = true
+	c.JSON(http.StatusOK, expense)
+}
+
+func GetExpenseReceipt(c *gin.Context) {
+	id := c.Param("id")
+
+	var expense Expense
+	if err := DB.First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if expense.ReceiptPath == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No receipt uploaded for this expense"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.File(*expense.ReceiptPath)
+}
@@ -0,0 +1,188 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// exportBatchSize is how many rows an export reads from the database at a time
+const exportBatchSize = 500
+
+// createdBetween keeps the rows created from the start of one date to the end of another, as the
+// revenue range and food order reports filter them
+func createdBetween(start, end string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("created_at BETWEEN ? AND ?", start+" 00:00:00", end+" 23:59:59")
+	}
+}
+
+// exportDatasets write the header and then the rows of each table that can be exported. Rows are read
+// in batches in ID order, since FindInBatches pages on the primary key.
+var exportDatasets = map[string]func(db *gorm.DB, start, end string, out tableWriter) error{
+	"income":       exportIncome,
+	"expenses":     exportExpenses,
+	"food-orders":  exportFoodOrders,
+	"guests":       exportGuests,
+	"reservations": exportReservations,
+}
+
+func exportIncome(db *gorm.DB, start, end string, out tableWriter) error {
+	if err := out.WriteRow("ID", "Created", "Business date", "Type", "Revenue type", "Payment method",
+		"Room", "Guest ID", "Amount", "Currency", "Original amount", "Exchange rate", "Reversal of", "Receptionist ID", "Shift ID"); err != nil {
+		return err
+	}
+
+	var batch []Income
+	return db.Scopes(createdBetween(start, end)).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, income := range batch {
+				if err := out.WriteRow(income.ID, income.CreatedAt, income.BusinessDate, income.Type, income.RevenueType,
+					income.PaymentMethod, income.RoomNumber, income.GuestID, income.Amount, income.Currency,
+					income.OriginalAmount, income.ExchangeRate, income.ReversalOfID,
+					income.ReceptionistID, income.ShiftID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportExpenses(db *gorm.DB, start, end string, out tableWriter) error {
+	if err := out.WriteRow("ID", "Date", "Category", "Vendor", "Description", "Payment method", "Amount",
+		"Approved by", "Receipt"); err != nil {
+		return err
+	}
+
+	var batch []Expense
+	return db.Where("expense_date BETWEEN ? AND ?", start, end).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, expense := range batch {
+				if err := out.WriteRow(expense.ID, expense.ExpenseDate, expense.Category, expense.Vendor,
+					expense.Description, expense.PaymentMethod, expense.Amount, expense.ApprovedBy,
+					expense.HasReceipt); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportFoodOrders(db *gorm.DB, start, end string, out tableWriter) error {
+	if err := out.WriteRow("ID", "Created", "Order type", "Guest ID", "Room ID", "Customer", "Status",
+		"Items", "Payment method", "Total"); err != nil {
+		return err
+	}
+
+	var batch []FoodOrder
+	return db.Preload("Items.Modifiers").Scopes(createdBetween(start, end)).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, order := range batch {
+				if err := out.WriteRow(order.ID, order.CreatedAt, order.OrderType, order.GuestID, order.RoomID,
+					order.CustomerName, order.Status, foodOrderSummary(order), order.PaymentMethod,
+					order.Total); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportGuests(db *gorm.DB, start, end string, out tableWriter) error {
+	if err := out.WriteRow("ID", "Name", "Phone", "Room type", "Room", "Check-in", "Check-out", "Status",
+		"Room charges", "Food charges", "Extra charges", "Service charges", "Tax", "Loyalty discount", "Amount paid",
+		"Payment type"); err != nil {
+		return err
+	}
+
+	var batch []Guests
+	return db.Where("checkin_date BETWEEN ? AND ?", start+" 00:00:00", end+" 23:59:59").
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, guest := range batch {
+				if err := out.WriteRow(guest.ID, guest.Name, guest.Phone, guest.RoomType, guest.RoomNumber,
+					guest.CheckinDate, guest.CheckoutDate, guest.Status, guest.RoomCharges, guest.FoodCharges,
+					guest.ExtraCharges, guest.ServiceCharges, guest.TaxCharges, guest.LoyaltyDiscount, guest.AmountPaid,
+					guest.PaymentType); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportReservations(db *gorm.DB, start, end string, out tableWriter) error {
+	if err := out.WriteRow("ID", "Name", "Phone", "Room type", "Rooms", "Guests", "Reservation date",
+		"Check-in", "Check-out", "Status", "Amount paid", "Payment type", "Notes"); err != nil {
+		return err
+	}
+
+	var batch []Reservation
+	return db.Where("reservation_date BETWEEN ? AND ?", start, end).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, reservation := range batch {
+				if err := out.WriteRow(reservation.ID, reservation.Name, reservation.Phone, reservation.RoomType,
+					reservation.RoomCount, reservation.GuestCount, reservation.ReservationDate,
+					reservation.CheckinDate, reservation.CheckoutDate, reservation.Status, reservation.AmountPaid,
+					reservation.PaymentType, reservation.Notes); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// ExportData streams one dataset over a date range as CSV, or as an Excel workbook with ?format=xlsx
+func ExportData(c *gin.Context) {
+	dataset := c.Param("dataset")
+	start, end := c.Param("start"), c.Param("end")
+
+	export, ok := exportDatasets[dataset]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Unknown export " + dataset})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", start); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start date format"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", end); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end date format"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	filename := fmt.Sprintf("%s_%s_%s.%s", dataset, start, end, format)
+
+	var out tableWriter
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		out = newCSVTableWriter(c.Writer)
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		xlsx, err := newXLSXTableWriter(c.Writer, dataset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to start export"})
+			return
+		}
+		out = xlsx
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Format must be csv or xlsx"})
+		return
+	}
+
+	// The response is already under way, so a failure part way through can't be reported with a status.
+	// The connection is cut instead, so the download fails rather than leaving a file that is missing rows.
+	if err := export(DB, start, end, out); err != nil {
+		fmt.Printf("Error exporting %s from %s to %s: %v\n", dataset, start, end, err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := out.Close(); err != nil {
+		fmt.Printf("Error finishing %s export: %v\n", dataset, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,211 @@
+package routes
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableWriter writes an export one row at a time, so large date ranges never sit in memory
+type tableWriter interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+}
+
+// exportCell formats a value for an export, reporting whether it is a number
+func exportCell(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, false
+	case *string:
+		if v == nil {
+			return "", false
+		}
+		return *v, false
+	case int:
+		return strconv.Itoa(v), true
+	case *int:
+		if v == nil {
+			return "", false
+		}
+		return strconv.Itoa(*v), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case *uint:
+		if v == nil {
+			return "", false
+		}
+		return strconv.FormatUint(uint64(*v), 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case *float64:
+		if v == nil {
+			return "", false
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64), true
+	case Money:
+		return v.Decimal(), true
+	case *Money:
+		if v == nil {
+			return "", false
+		}
+		return v.Decimal(), true
+	case bool:
+		if v {
+			return "Yes", false
+		}
+		return "No", false
+	case time.Time:
+		if v.IsZero() {
+			return "", false
+		}
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02"), false
+		}
+		return v.Format("2006-01-02 15:04:05"), false
+	case *time.Time:
+		if v == nil {
+			return "", false
+		}
+		return exportCell(*v)
+	}
+	return fmt.Sprint(value), false
+}
+
+type csvTableWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVTableWriter(w io.Writer) *csvTableWriter {
+	return &csvTableWriter{writer: csv.NewWriter(w)}
+}
+
+func (t *csvTableWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i], _ = exportCell(value)
+	}
+	return t.writer.Write(record)
+}
+
+func (t *csvTableWriter) Close() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+// xlsxTableWriter writes a single-sheet workbook. The fixed parts of the package are written up front
+// and the sheet is streamed into the zip last, with strings stored inline so no shared string table
+// has to be built.
+type xlsxTableWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+func newXLSXTableWriter(w io.Writer, sheetName string) (*xlsxTableWriter, error) {
+	archive := zip.NewWriter(w)
+
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(sheetName))
+	parts := []struct {
+		path    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, name.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	t := &xlsxTableWriter{zip: archive, sheet: bufio.NewWriter(sheet)}
+	_, err = t.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return t, err
+}
+
+// xlsxColumn is the column letter for a zero-based index: A, B, ... Z, AA, AB, ...
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func (t *xlsxTableWriter) WriteRow(values ...interface{}) error {
+	t.row++
+	fmt.Fprintf(t.sheet, `<row r="%d">`, t.row)
+	for i, value := range values {
+		text, numeric := exportCell(value)
+		if text == "" {
+			continue
+		}
+		ref := xlsxColumn(i) + strconv.Itoa(t.row)
+		if numeric {
+			fmt.Fprintf(t.sheet, `<c r="%s"><v>%s</v></c>`, ref, text)
+			continue
+		}
+		fmt.Fprintf(t.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		if err := xml.EscapeText(t.sheet, []byte(text)); err != nil {
+			return err
+		}
+		t.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := t.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (t *xlsxTableWriter) Close() error {
+	if _, err := t.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := t.sheet.Flush(); err != nil {
+		return err
+	}
+	return t.zip.Close()
+}
@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fakeWalletSignatureHeader carries the hex HMAC-SHA256 of a fake wallet webhook body
+const fakeWalletSignatureHeader = "X-Fake-Wallet-Signature"
+
+// fakeWallet is a local stand-in for the real wallets so the payment flow can be run end to end without
+// them. Payments are held in memory and are paid with FakeWalletPay. It is never available unless
+// EnableFakeWallet is called.
+type fakeWallet struct {
+	config PaymentProviderConfig
+}
+
+var fakeWalletPayments = struct {
+	sync.Mutex
+	status map[string]string
+}{status: map[string]string{}}
+
+// EnableFakeWallet registers the fake wallet as a provider. It is only for development and testing, where
+// main turns it on with -fake-wallet along with the FakeWalletPay route.
+func EnableFakeWallet() {
+	RegisterPaymentProvider("fake", func(config PaymentProviderConfig) PaymentProvider {
+		return &fakeWallet{config: config}
+	})
+}
+
+func (wallet *fakeWallet) CreatePayment(request WalletPaymentRequest) (WalletPaymentSession, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return WalletPaymentSession{}, err
+	}
+	reference := fmt.Sprintf("FAKE-%d-%s", request.PaymentID, hex.EncodeToString(random))
+
+	fakeWalletPayments.Lock()
+	fakeWalletPayments.status[reference] = "PENDING"
+	fakeWalletPayments.Unlock()
+
+	query := url.Values{}
+	query.Set("merchant", wallet.config.MerchantID)
+	query.Set("ref", reference)
+	query.Set("amount", request.Amount.Decimal())
+	return WalletPaymentSession{
+		Reference: reference,
+		QRPayload: "fakewallet://pay?" + query.Encode(),
+		ExpiresAt: time.Now().UTC().Add(15 * time.Minute),
+	}, nil
+}
+
+func (wallet *fakeWallet) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(wallet.config.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wallet *fakeWallet) VerifyWebhook(header http.Header, body []byte) (WalletPaymentUpdate, error) {
+	signature, err := hex.DecodeString(header.Get(fakeWalletSignatureHeader))
+	if err != nil {
+		return WalletPaymentUpdate{}, errors.New("malformed signature")
+	}
+	expected, _ := hex.DecodeString(wallet.sign(body))
+	if !hmac.Equal(signature, expected) {
+		return WalletPaymentUpdate{}, errors.New("signature mismatch")
+	}
+
+	var update WalletPaymentUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return WalletPaymentUpdate{}, err
+	}
+	return update, nil
+}
+
+func (wallet *fakeWallet) QueryStatus(reference string) (string, error) {
+	fakeWalletPayments.Lock()
+	defer fakeWalletPayments.Unlock()
+
+	status, ok := fakeWalletPayments.status[reference]
+	if !ok {
+		return "", fmt.Errorf("unknown payment %s", reference)
+	}
+	return status, nil
+}
+
+// FakeWalletPay plays the guest paying, or declining, a fake wallet payment. It returns the signed webhook
+// the fake wallet would send so the webhook endpoint can be exercised with it.
+func FakeWalletPay(c *gin.Context) {
+	reference := c.Param("reference")
+	status := "CONFIRMED"
+	if c.Query("decline") == "true" {
+		status = "FAILED"
+	}
+
+	var payment Payment
+	if err := DB.Where("provider_reference = ?", reference).First(&payment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+		return
+	}
+	provider, err := providerFor(DB, payment.Method)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	wallet, ok := provider.(*fakeWallet)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": payment.Method + " doesn't use the fake wallet"})
+		return
+	}
+
+	fakeWalletPayments.Lock()
+	_, known := fakeWalletPayments.status[reference]
+	if known {
+		fakeWalletPayments.status[reference] = status
+	}
+	fakeWalletPayments.Unlock()
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"message": "The fake wallet doesn't know this payment"})
+		return
+	}
+
+	body, _ := json.Marshal(WalletPaymentUpdate{Reference: reference, Status: status})
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"webhook": gin.H{
+			"url":       "/payments/webhook/" + payment.Method,
+			"header":    fakeWalletSignatureHeader,
+			"signature": wallet.sign(body),
+			"body":      string(body),
+		},
+	})
+}
@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestFakeWalletVerifyWebhook(t *testing.T) {
+	wallet := &fakeWallet{config: PaymentProviderConfig{Method: "KPAY", Provider: "fake", Secret: "test-secret"}}
+
+	session, err := wallet.CreatePayment(WalletPaymentRequest{PaymentID: 1, Amount: kyat(15000)})
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	status, err := wallet.QueryStatus(session.Reference)
+	if err != nil || status != "PENDING" {
+		t.Fatalf("QueryStatus = %q, %v; want PENDING", status, err)
+	}
+
+	body, _ := json.Marshal(WalletPaymentUpdate{Reference: session.Reference, Status: "CONFIRMED"})
+	header := http.Header{}
+	header.Set(fakeWalletSignatureHeader, wallet.sign(body))
+
+	update, err := wallet.VerifyWebhook(header, body)
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if update.Reference != session.Reference || update.Status != "CONFIRMED" {
+		t.Errorf("VerifyWebhook = %+v", update)
+	}
+
+	tampered := []byte(strings.Replace(string(body), "CONFIRMED", "FAILED", 1))
+	if _, err := wallet.VerifyWebhook(header, tampered); err == nil {
+		t.Error("VerifyWebhook accepted a body that doesn't match its signature")
+	}
+	other := &fakeWallet{config: PaymentProviderConfig{Secret: "another-secret"}}
+	header.Set(fakeWalletSignatureHeader, other.sign(body))
+	if _, err := wallet.VerifyWebhook(header, body); err == nil {
+		t.Error("VerifyWebhook accepted a body signed with another secret")
+	}
+}
+
+// TestWalletPaymentFlow runs a wallet payment from start to confirmation against a MySQL database. Set
+// AUREO_TEST_DSN to a scratch database to run it; the test creates the tables it needs there.
+func TestWalletPaymentFlow(t *testing.T) {
+	dsn := os.Getenv("AUREO_TEST_DSN")
+	if dsn == "" {
+		t.Skip("AUREO_TEST_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+
+	if err := DB.AutoMigrate(&Guests{}, &IdentityDocument{}, &Reservation{}, &GuestProfile{}, &LoyaltyTransaction{},
+		&Payment{}, &PaymentProviderConfig{}, &Income{}, &CashierShift{}, &ShiftCount{}, &DailyClose{},
+		&Account{}, &JournalEntry{}, &JournalLine{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	if err := seedChartOfAccounts(DB); err != nil {
+		t.Fatalf("seedChartOfAccounts: %v", err)
+	}
+	if err := DB.Save(&PaymentProviderConfig{Method: "KPAY", Provider: "fake", Secret: "test-secret", Enabled: true}).Error; err != nil {
+		t.Fatalf("Failed to configure the fake wallet: %v", err)
+	}
+	guest := Guests{
+		Name:         "Wallet Test",
+		RoomNumber:   901,
+		CheckinDate:  time.Now().UTC(),
+		CheckoutDate: time.Now().UTC().AddDate(0, 0, 1),
+	}
+	if err := DB.Create(&guest).Error; err != nil {
+		t.Fatalf("Failed to create guest: %v", err)
+	}
+
+	EnableFakeWallet()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/guests/:id/wallet-payments", StartWalletPayment)
+	router.POST("/payments/fake/:reference/pay", FakeWalletPay)
+	router.POST("/payments/webhook/:method", WalletWebhook)
+	router.GET("/payments/:id/status", GetPaymentStatus)
+
+	call := func(method, path, body string, header http.Header, into interface{}) {
+		t.Helper()
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		for name := range header {
+			request.Header.Set(name, header.Get(name))
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, response.Code, response.Body.String())
+		}
+		if into != nil {
+			if err := json.Unmarshal(response.Body.Bytes(), into); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+	}
+
+	var started struct {
+		Payment Payment `json:"payment"`
+	}
+	call("POST", "/guests/"+strconv.Itoa(guest.ID)+"/wallet-payments", `{"Amount": 15000, "Method": "KPAY"}`, nil, &started)
+	if started.Payment.Status != "PENDING" || started.Payment.ProviderReference == nil {
+		t.Fatalf("started payment = %+v; want a pending payment with a wallet reference", started.Payment)
+	}
+
+	var paid struct {
+		Webhook struct {
+			Header    string `json:"header"`
+			Signature string `json:"signature"`
+			Body      string `json:"body"`
+		} `json:"webhook"`
+	}
+	call("POST", "/payments/fake/"+*started.Payment.ProviderReference+"/pay", "", nil, &paid)
+
+	// The wallet can deliver the same webhook more than once; the payment is only booked the first time
+	header := http.Header{}
+	header.Set(paid.Webhook.Header, paid.Webhook.Signature)
+	for i := 0; i < 2; i++ {
+		var webhook struct {
+			Status string `json:"status"`
+		}
+		call("POST", "/payments/webhook/KPAY", paid.Webhook.Body, header, &webhook)
+		if webhook.Status != "CONFIRMED" {
+			t.Fatalf("webhook %d status = %q; want CONFIRMED", i+1, webhook.Status)
+		}
+	}
+
+	var status Payment
+	call("GET", "/payments/"+strconv.FormatUint(uint64(started.Payment.ID), 10)+"/status", "", nil, &status)
+	if status.Status != "CONFIRMED" || status.IncomeID == nil {
+		t.Fatalf("payment = %+v; want it confirmed and booked", status)
+	}
+
+	var incomes int64
+	DB.Model(&Income{}).Where("guest_id = ?", guest.ID).Count(&incomes)
+	if incomes != 1 {
+		t.Errorf("income rows for the stay = %d; want 1", incomes)
+	}
+	DB.First(&guest, guest.ID)
+	if guest.AmountPaid == nil || guest.AmountPaid.Minor != kyat(15000).Minor {
+		t.Errorf("amount paid = %v; want 15000", guest.AmountPaid)
+	}
+}
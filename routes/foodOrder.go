@@ -2,22 +2,71 @@ package routes
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type FoodOrder struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement"`
-	GuestID   uint      `gorm:"not null"`
-	RoomID    uint      `gorm:"not null"`
-	FoodName  string    `gorm:"not null"`
-	Price     float64   `gorm:"not null"`
-	Quantity  uint      `gorm:"not null"`
-	OrderTime time.Time `gorm:"type:datetime;not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID            uint            `gorm:"primaryKey;autoIncrement"`
+	GuestID       uint            `gorm:"not null"` // 0 for walk-in orders
+	RoomID        uint            `gorm:"not null"` // 0 for walk-in orders
+	OrderType     string          `gorm:"type:enum('ROOM_SERVICE','WALK_IN');not null;default:'ROOM_SERVICE'"`
+	CustomerName  *string         `gorm:"null"`       // Walk-in customer, if they gave a name
+	PaymentMethod *string         `gorm:"null"`       // How a walk-in order was paid
+	IncomeID      *uint           `gorm:"null;index"` // Income row recording a walk-in order's payment
+	OrderTime     time.Time       `gorm:"type:datetime;not null"`
+	Notes         *string         `gorm:"type:text;null"`
+	Total         Money           `gorm:"not null;default:0"`
+	Status        string          `gorm:"type:enum('PLACED','ACCEPTED','PREPARING','READY','DELIVERED','CANCELLED');default:'PLACED'"`
+	AcceptedAt    *time.Time      `gorm:"type:datetime"`
+	PreparingAt   *time.Time      `gorm:"type:datetime"`
+	ReadyAt       *time.Time      `gorm:"type:datetime"`
+	DeliveredAt   *time.Time      `gorm:"type:datetime"`
+	CancelledAt   *time.Time      `gorm:"type:datetime"`
+	BusinessDate  *time.Time      `gorm:"type:date"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime"`
+	Items         []FoodOrderItem `gorm:"foreignKey:FoodOrderID"`
+}
+
+// ErrGuestNotActive is returned when a charge is posted to a stay that has already checked out
+var ErrGuestNotActive = errors.New("guest stay is not active")
+
+type FoodOrderItem struct {
+	ID          uint                    `gorm:"primaryKey;autoIncrement"`
+	FoodOrderID uint                    `gorm:"not null;index"`
+	MenuID      uint                    `gorm:"not null"`
+	FoodName    string                  `gorm:"not null"` // Copied from the menu so later menu edits don't change past orders
+	UnitPrice   Money                   `gorm:"not null"`
+	Quantity    uint                    `gorm:"not null"`
+	LineTotal   Money                   `gorm:"not null"`
+	Modifiers   []FoodOrderItemModifier `gorm:"foreignKey:FoodOrderItemID"`
+}
+
+type foodOrderRequest struct {
+	GuestID   uint
+	RoomID    uint
+	OrderTime time.Time
+	Notes     *string
+	Items     []foodOrderItemRequest
+}
+
+type foodOrderItemRequest struct {
+	MenuID    uint
+	Quantity  uint
+	OptionIDs []uint // Modifier options chosen for this line
+}
+
+// businessDate is the business date the order is booked to, falling back to its order time for older rows
+func (order *FoodOrder) businessDate() time.Time {
+	if order.BusinessDate != nil {
+		return *order.BusinessDate
+	}
+	return order.OrderTime
 }
 
 func (order *FoodOrder) BeforeCreate(tx *gorm.DB) error {
@@ -27,26 +76,48 @@ func (order *FoodOrder) BeforeCreate(tx *gorm.DB) error {
 	if order.OrderTime.IsZero() {
 		order.OrderTime = now
 	}
-	return nil
+	if order.BusinessDate == nil {
+		businessDate, err := currentBusinessDate(hookDB(tx))
+		if err != nil {
+			return err
+		}
+		order.BusinessDate = &businessDate
+	}
+	return checkBusinessDateOpen(hookDB(tx), *order.BusinessDate)
 }
 
 func (order *FoodOrder) BeforeUpdate(tx *gorm.DB) error {
 	order.UpdatedAt = time.Now().UTC()
-	return nil
+	return checkBusinessDateOpen(hookDB(tx), order.businessDate())
+}
+
+func (order *FoodOrder) BeforeDelete(tx *gorm.DB) error {
+	return checkBusinessDateOpen(hookDB(tx), order.businessDate())
 }
 
 type Menu struct {
-	ID        uint   `gorm:"primaryKey;autoIncrement"`
-	FoodName  string `gorm:"not null"`
-	FoodPrice string `gorm:"not null"`
+	ID            uint          `gorm:"primaryKey;autoIncrement"`
+	FoodName      string        `gorm:"not null"`
+	Price         Money         `gorm:"not null;default:0"`
+	CategoryID    *uint         `gorm:"null;index"`
+	Category      *MenuCategory `gorm:"foreignKey:CategoryID"`
+	Description   *string       `gorm:"type:text;null"`
+	Active        *bool         `gorm:"not null;default:true"`
+	SoldOut       bool          `gorm:"not null;default:false"`
+	AvailableFrom *string       `gorm:"type:varchar(5);null"` // HH:MM in hotel time
+	AvailableTo   *string       `gorm:"type:varchar(5);null"`
+	PhotoPath     *string       `gorm:"null"`
 }
 
+// DailyFoodRevenue is the food revenue rollup for one day, kept up to date as orders change
 type DailyFoodRevenue struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement"`
-	Date      time.Time `gorm:"type:date;uniqueIndex"`
-	Revenue   float64   `gorm:"not null;default:0"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	Date        time.Time `gorm:"type:date;uniqueIndex"`
+	Revenue     Money     `gorm:"not null;default:0"`
+	RoomService Money     `gorm:"not null;default:0"`
+	WalkIn      Money     `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
 }
 
 func CreateMenu(c *gin.Context) {
@@ -57,6 +128,13 @@ func CreateMenu(c *gin.Context) {
 		return
 	}
 
+	if err := validateMenu(&menu); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	menu.Category = nil
+	menu.PhotoPath = nil
+
 	if err := DB.Create(&menu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create menu: " + err.Error()})
 		return
@@ -67,13 +145,14 @@ func CreateMenu(c *gin.Context) {
 	})
 }
 
+// GetMenu lists menu items, optionally filtered by ?category= (ID or name) and ?available=true
 func GetMenu(c *gin.Context) {
 	var menu []Menu
-	if err := DB.Find(&menu).Error; err != nil {
+	if err := DB.Preload("Category").Scopes(menuCategoryScope(c)).Find(&menu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, menu)
+	c.JSON(http.StatusOK, filterAvailable(c, menu))
 }
 
 func GetMenuByID(c *gin.Context) {
@@ -84,7 +163,7 @@ func GetMenuByID(c *gin.Context) {
 	}
 
 	var menu Menu
-	if err := DB.Find(&menu, id).Error; err != nil {
+	if err := DB.Preload("Category").First(&menu, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Food not found"})
 			return
@@ -122,12 +201,6 @@ func UpdateMenu(c *gin.Context) {
 		return
 	}
 
-	var menu Menu
-	if err := c.BindJSON(&menu); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
-	}
-
 	var existingMenu Menu
 	if err := DB.First(&existingMenu, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -138,7 +211,24 @@ func UpdateMenu(c *gin.Context) {
 		return
 	}
 
-	if err := DB.Model(&existingMenu).Updates(menu).Error; err != nil {
+	// Bind over the stored item so fields left out of the request keep their values,
+	// while flags like SoldOut can still be switched back to false
+	menuID := existingMenu.ID
+	photoPath := existingMenu.PhotoPath
+	if err := c.BindJSON(&existingMenu); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	existingMenu.ID = menuID
+	existingMenu.PhotoPath = photoPath
+	existingMenu.Category = nil
+
+	if err := validateMenu(&existingMenu); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := DB.Save(&existingMenu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
@@ -169,30 +259,213 @@ func DeleteMenu(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Menu deleted successfully"})
 }
 
+// buildOrderItems prices each requested line from the menu and returns the lines with the order total
+func buildOrderItems(tx *gorm.DB, requested []foodOrderItemRequest) ([]FoodOrderItem, Money, error) {
+	if len(requested) == 0 {
+		return nil, Money{}, errors.New("an order needs at least one item")
+	}
+
+	var items []FoodOrderItem
+	var total Money
+	for _, line := range requested {
+		if line.Quantity == 0 {
+			return nil, Money{}, errors.New("item quantity must be at least 1")
+		}
+
+		var menu Menu
+		if err := tx.First(&menu, line.MenuID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, Money{}, fmt.Errorf("menu item %d not found", line.MenuID)
+			}
+			return nil, Money{}, err
+		}
+
+		if !menu.availableAt(time.Now()) {
+			return nil, Money{}, fmt.Errorf("%s is not available right now", menu.FoodName)
+		}
+
+		modifiers, delta, err := resolveModifiers(tx, menu, line.OptionIDs)
+		if err != nil {
+			return nil, Money{}, err
+		}
+
+		unitPrice := menu.Price.Add(delta)
+		if unitPrice.Sign() < 0 {
+			unitPrice = Money{}
+		}
+		item := FoodOrderItem{
+			MenuID:    menu.ID,
+			FoodName:  menu.FoodName,
+			UnitPrice: unitPrice,
+			Quantity:  line.Quantity,
+			LineTotal: unitPrice.Times(int(line.Quantity)),
+			Modifiers: modifiers,
+		}
+		total = total.Add(item.LineTotal)
+		items = append(items, item)
+	}
+	return items, total, nil
+}
+
+// foodOrderSummary describes the order's items in one line, e.g. "Fried rice [Extra egg] (x2), Lime juice (x1)"
+func foodOrderSummary(order FoodOrder) string {
+	parts := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		name := item.FoodName
+		if len(item.Modifiers) > 0 {
+			options := make([]string, 0, len(item.Modifiers))
+			for _, modifier := range item.Modifiers {
+				options = append(options, modifier.OptionName)
+			}
+			name += " [" + strings.Join(options, ", ") + "]"
+		}
+		parts = append(parts, fmt.Sprintf("%s (x%d)", name, item.Quantity))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// deleteOrderItems removes an order's lines along with the modifiers chosen on them
+func deleteOrderItems(tx *gorm.DB, orderID uint) error {
+	itemIDs := tx.Model(&FoodOrderItem{}).Select("id").Where("food_order_id = ?", orderID)
+	if err := tx.Where("food_order_item_id IN (?)", itemIDs).Delete(&FoodOrderItemModifier{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("food_order_id = ?", orderID).Delete(&FoodOrderItem{}).Error
+}
+
+// postFoodCharge adds delta to the food charges of the guest's stay, which must still be active
+func postFoodCharge(tx *gorm.DB, guestID uint, delta Money) error {
+	result := tx.Model(&Guests{}).
+		Where("id = ? AND status = ?", guestID, "ACTIVE").
+		Update("food_charges", gorm.Expr("food_charges + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGuestNotActive
+	}
+	return nil
+}
+
+// chargeFoodOrder applies a change in an order's total to whoever pays for it, the guest's stay for room
+// service or the walk-in customer, and to the daily food revenue rollup. When the total comes down, the
+// order must still have its total from before the change. takenBy is the receptionist handling any money
+// for a walk-in order.
+func chargeFoodOrder(tx *gorm.DB, order *FoodOrder, delta Money, takenBy *int) error {
+	if delta.IsZero() {
+		return nil
+	}
+	lines := tx.Model(&TaxLine{}).Where("source_type = ? AND source_id = ?", "FOOD_ORDER", order.ID)
+	splits, err := changeTaxSplits(tx, lines, "FOOD", order.Total, delta)
+	if err != nil {
+		return err
+	}
+
+	var guestID *int
+	if order.OrderType != "WALK_IN" {
+		if err := postFoodCharge(tx, order.GuestID, delta); err != nil {
+			return err
+		}
+		id := int(order.GuestID)
+		guestID = &id
+		for _, split := range splits {
+			if err := postFolioCharge(tx, "FOOD_ORDER", order.ID, accountFoodRevenue, split, fmt.Sprintf("Food order #%d", order.ID)); err != nil {
+				return err
+			}
+		}
+	} else if order.IncomeID != nil {
+		if err := settleWalkInChange(tx, order, delta, splits, takenBy); err != nil {
+			return err
+		}
+	}
+	for _, split := range splits {
+		if err := recordTax(tx, guestID, "FOOD", "FOOD_ORDER", order.ID, time.Time{}, split); err != nil {
+			return err
+		}
+	}
+	return postFoodRevenue(tx, order, delta)
+}
+
+// respondFoodOrderError maps the errors raised while changing an order to a response
+func respondFoodOrderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrBusinessDateClosed):
+		c.JSON(http.StatusConflict, gin.H{"message": "Business date is closed"})
+	case errors.Is(err, ErrGuestNotActive):
+		c.JSON(http.StatusConflict, gin.H{"message": "Guest stay is not active"})
+	case errors.Is(err, ErrNoOpenShift):
+		c.JSON(http.StatusConflict, gin.H{"message": "Open a cashier shift before taking cash"})
+	case errors.Is(err, ErrInvalidStatusTransition):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, ErrPaidOrderNeedsDesk):
+		c.JSON(http.StatusConflict, gin.H{"message": "A paid walk-in order has to be cancelled at the front desk so the money can be given back"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": message})
+	}
+}
+
+// CreateFoodOrder places a whole basket as one order, priced from the menu
 func CreateFoodOrder(c *gin.Context) {
-	var order FoodOrder
+	var request foodOrderRequest
 
-	if err := c.BindJSON(&order); err != nil {
+	if err := c.BindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	if err := DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create food order: " + err.Error()})
+	tx := DB.Begin()
+
+	// The order is charged to the guest's stay, so it must be active and in the ordering room
+	var guest Guests
+	if err := tx.Where("id = ? AND status = ?", request.GuestID, "ACTIVE").First(&guest).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "No active stay found for this guest"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+	if request.RoomID == 0 {
+		request.RoomID = uint(guest.RoomNumber)
+	}
+	if request.RoomID != uint(guest.RoomNumber) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Guest is staying in room %d, not room %d", guest.RoomNumber, request.RoomID)})
 		return
 	}
 
-	var guest Guests
-	if err := DB.First(&guest, "room_number = ?", order.RoomID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest food charges"})
+	items, total, err := buildOrderItems(tx, request.Items)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	guest.FoodCharges += int(order.Price)
-	if err := DB.Save(&guest).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest food charges"})
+	// New orders always start at the beginning of the kitchen workflow
+	order := FoodOrder{
+		GuestID:   request.GuestID,
+		RoomID:    request.RoomID,
+		OrderType: "ROOM_SERVICE",
+		OrderTime: request.OrderTime,
+		Notes:     request.Notes,
+		Total:     total,
+		Status:    "PLACED",
+		Items:     items,
+	}
+
+	if err := tx.Create(&order).Error; err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to create food order: "+err.Error())
+		return
+	}
+
+	if err := chargeFoodOrder(tx, &order, order.Total, nil); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to update guest food charges")
 		return
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Food order created successfully",
@@ -204,7 +477,7 @@ func GetFoodOrder(c *gin.Context) {
 	id := c.Param("id")
 	var order FoodOrder
 
-	if err := DB.First(&order, id).Error; err != nil {
+	if err := DB.Preload("Items.Modifiers").First(&order, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Food order not found"})
 			return
@@ -220,7 +493,7 @@ func GetFoodOrdersByRoom(c *gin.Context) {
 	roomID := c.Param("roomId")
 	var orders []FoodOrder
 
-	if err := DB.Where("room_id = ?", roomID).Find(&orders).Error; err != nil {
+	if err := DB.Preload("Items.Modifiers").Where("room_id = ?", roomID).Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch food orders"})
 		return
 	}
@@ -232,7 +505,7 @@ func GetFoodOrdersByGuestID(c *gin.Context) {
 	guestID := c.Param("guestId")
 
 	var orders []FoodOrder
-	if err := DB.Where("guest_id = ?", guestID).Find(&orders).Error; err != nil {
+	if err := DB.Preload("Items.Modifiers").Where("guest_id = ?", guestID).Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch food orders"})
 		return
 	}
@@ -240,11 +513,26 @@ func GetFoodOrdersByGuestID(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+// UpdateFoodOrder changes an order's notes, items or status and posts the difference to the guest's food charges
 func UpdateFoodOrder(c *gin.Context) {
 	id := c.Param("id")
-	var order FoodOrder
 
-	if err := DB.First(&order, id).Error; err != nil {
+	var updateData struct {
+		Status string `json:"status"`
+		Notes  *string
+		Items  []foodOrderItemRequest
+	}
+
+	if err := c.BindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var order FoodOrder
+	if err := tx.Preload("Items.Modifiers").First(&order, id).Error; err != nil {
+		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Food order not found"})
 			return
@@ -253,14 +541,63 @@ func UpdateFoodOrder(c *gin.Context) {
 		return
 	}
 
-	var updateData struct {
-		Status string `json:"status"`
+	if updateData.Notes != nil {
+		if err := tx.Model(&order).Update("notes", *updateData.Notes).Error; err != nil {
+			tx.Rollback()
+			respondFoodOrderError(c, err, "Failed to update food order")
+			return
+		}
 	}
 
-	if err := c.BindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
+	if len(updateData.Items) > 0 {
+		if order.Status == "DELIVERED" || order.Status == "CANCELLED" {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Items can't be changed on a " + order.Status + " order"})
+			return
+		}
+
+		items, total, err := buildOrderItems(tx, updateData.Items)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := deleteOrderItems(tx, order.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update order items"})
+			return
+		}
+		for i := range items {
+			items[i].FoodOrderID = order.ID
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update order items"})
+			return
+		}
+
+		if err := chargeFoodOrder(tx, &order, total.Sub(order.Total), receptionistID(c)); err != nil {
+			tx.Rollback()
+			respondFoodOrderError(c, err, "Failed to update guest food charges")
+			return
+		}
+		if err := tx.Model(&order).Update("total", total).Error; err != nil {
+			tx.Rollback()
+			respondFoodOrderError(c, err, "Failed to update food order")
+			return
+		}
+		order.Items = items
+	}
+
+	if updateData.Status != "" && updateData.Status != order.Status {
+		if err := applyFoodOrderStatus(tx, &order, updateData.Status, receptionistID(c)); err != nil {
+			tx.Rollback()
+			respondFoodOrderError(c, err, "Failed to update order status")
+			return
+		}
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Food order updated successfully",
@@ -270,9 +607,12 @@ func UpdateFoodOrder(c *gin.Context) {
 
 func DeleteFoodOrder(c *gin.Context) {
 	id := c.Param("id")
-	var order FoodOrder
 
-	if err := DB.First(&order, id).Error; err != nil {
+	tx := DB.Begin()
+
+	var order FoodOrder
+	if err := tx.First(&order, id).Error; err != nil {
+		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Food order not found"})
 			return
@@ -281,83 +621,88 @@ func DeleteFoodOrder(c *gin.Context) {
 		return
 	}
 
-	// Update guest's food charges before deleting the order
-	var guest Guests
-	if err := DB.First(&guest, "room_number = ?", order.RoomID).Error; err == nil {
-		guest.FoodCharges -= int(order.Price)
-		DB.Save(&guest)
+	// Take the order off the guest's food charges, unless cancelling already did
+	if order.Status != "CANCELLED" {
+		if err := chargeFoodOrder(tx, &order, order.Total.Neg(), receptionistID(c)); err != nil {
+			tx.Rollback()
+			respondFoodOrderError(c, err, "Failed to update guest food charges")
+			return
+		}
+	}
+
+	if err := deleteOrderItems(tx, order.ID); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to delete food order")
+		return
 	}
 
-	if err := DB.Delete(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete food order"})
+	if err := tx.Delete(&order).Error; err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to delete food order")
 		return
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Food order deleted successfully"})
 }
 
-func GetDailyFoodRevenue() float64 {
-	now := time.Now().UTC()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-
-	var foodOrders []FoodOrder
-	if err := DB.Where("DATE(order_time) = ?", today.Format("2006-01-02")).Find(&foodOrders).Error; err != nil {
-		return 0
-	}
+// GetDailyFoodRevenue returns today's food revenue from the rollup
+func GetDailyFoodRevenue() Money {
+	today := time.Now().Format("2006-01-02")
 
-	var totalRevenue float64
-	for _, order := range foodOrders {
-		totalRevenue += order.Price * float64(order.Quantity)
+	days, err := rolledUpFoodRevenue(DB, today, today)
+	if err != nil || len(days) == 0 {
+		return Money{}
 	}
-	return totalRevenue
+	return days[0].Total
 }
 
 func GetTodayFoodRevenue(c *gin.Context) {
-	var totalRevenue float64
-	now := time.Now().UTC()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-
-	if err := DB.Model(&FoodOrder{}).
-		Where("DATE(order_time) = ?", today).
-		Select("COALESCE(SUM(price * quantity), 0)").
-		Scan(&totalRevenue).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate today's food revenue"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"foodRevenue": totalRevenue})
+	today := time.Now().Format("2006-01-02")
+	respondFoodRevenue(c, today, "Failed to calculate today's food revenue")
 }
 
 func GetFoodRevenueByDate(c *gin.Context) {
-	date := c.Param("date")
-	var totalRevenue float64
-
-	if err := DB.Model(&FoodOrder{}).
-		Where("DATE(order_time) = ?", date).
-		Select("COALESCE(SUM(price * quantity), 0)").
-		Scan(&totalRevenue).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate food revenue for the date"})
+	respondFoodRevenue(c, c.Param("date"), "Failed to calculate food revenue for the date")
+}
+
+// respondFoodRevenue returns a day's food revenue along with its room-service and walk-in split
+func respondFoodRevenue(c *gin.Context, date string, message string) {
+	days, err := rolledUpFoodRevenue(DB, date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": message})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"foodRevenue": totalRevenue})
+	day := foodRevenueBreakdown{}
+	if len(days) > 0 {
+		day = days[0]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"foodRevenue": day.Total,
+		"roomService": day.RoomService,
+		"walkIn":      day.WalkIn,
+	})
 }
 
+// SearchMenu finds menu items by name, with the same category and availability filters as GetMenu
 func SearchMenu(c *gin.Context) {
 	searchTerm := c.Query("term")
 	var menus []Menu
 
+	query := DB.Preload("Category").Scopes(menuCategoryScope(c))
 	if searchTerm != "" {
-		if err := DB.Where("food_name LIKE ?", "%"+searchTerm+"%").Find(&menus).Error; err != nil {
+		if err := query.Where("food_name LIKE ?", "%"+searchTerm+"%").Find(&menus).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to search menu items"})
 			return
 		}
 	} else {
-		if err := DB.Find(&menus).Error; err != nil {
+		if err := query.Find(&menus).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch menu items"})
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, menus)
+	c.JSON(http.StatusOK, filterAvailable(c, menus))
 }
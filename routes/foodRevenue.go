@@ -0,0 +1,180 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// foodRevenueDate is the rollup date an order counts towards. order_time is written in the connection's
+// time zone (loc=Local), so bucketing by the local date matches DATE(order_time) in SQL.
+func foodRevenueDate(order *FoodOrder) time.Time {
+	local := order.OrderTime.In(time.Local)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// postFoodRevenue adds a change in an order's total to the DailyFoodRevenue rollup for its day. It runs in
+// the same transaction as the order change so the rollup can't drift from the orders.
+func postFoodRevenue(tx *gorm.DB, order *FoodOrder, delta Money) error {
+	if delta.IsZero() {
+		return nil
+	}
+
+	row := DailyFoodRevenue{Date: foodRevenueDate(order), Revenue: delta}
+	updates := map[string]interface{}{
+		"revenue":    gorm.Expr("revenue + ?", delta),
+		"updated_at": time.Now(),
+	}
+	if order.OrderType == "WALK_IN" {
+		row.WalkIn = delta
+		updates["walk_in"] = gorm.Expr("walk_in + ?", delta)
+	} else {
+		row.RoomService = delta
+		updates["room_service"] = gorm.Expr("room_service + ?", delta)
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(&row).Error
+}
+
+// rebuildFoodRevenue recomputes the rollup rows between start and end, inclusive, from the orders themselves
+func rebuildFoodRevenue(tx *gorm.DB, start, end string) error {
+	if err := tx.Where("date BETWEEN ? AND ?", start, end).Delete(&DailyFoodRevenue{}).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO daily_food_revenues (date, revenue, room_service, walk_in, created_at, updated_at)
+		SELECT DATE(order_time),
+			SUM(total),
+			SUM(CASE WHEN order_type = 'WALK_IN' THEN 0 ELSE total END),
+			SUM(CASE WHEN order_type = 'WALK_IN' THEN total ELSE 0 END),
+			NOW(), NOW()
+		FROM food_orders
+		WHERE DATE(order_time) BETWEEN ? AND ? AND status <> 'CANCELLED'
+		GROUP BY DATE(order_time)`, start, end).Error
+}
+
+// BackfillFoodRevenue rebuilds the rollup for a date range; main runs it for the -backfill-food-revenue flag
+func BackfillFoodRevenue(start, end string) error {
+	if _, err := time.Parse("2006-01-02", start); err != nil {
+		return fmt.Errorf("invalid start date %q, use YYYY-MM-DD", start)
+	}
+	if _, err := time.Parse("2006-01-02", end); err != nil {
+		return fmt.Errorf("invalid end date %q, use YYYY-MM-DD", end)
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return rebuildFoodRevenue(tx, start, end)
+	})
+}
+
+// rolledUpFoodRevenue reads the rollup rows between start and end, inclusive
+func rolledUpFoodRevenue(db *gorm.DB, start, end string) ([]foodRevenueBreakdown, error) {
+	var days []foodRevenueBreakdown
+	err := db.Model(&DailyFoodRevenue{}).
+		Select("DATE_FORMAT(date, '%Y-%m-%d') as date, room_service, walk_in, revenue as total").
+		Where("date BETWEEN ? AND ?", start, end).
+		Order("date").
+		Scan(&days).Error
+	return days, err
+}
+
+// RunFoodRevenueBackfill rebuilds the rollup for a date range from the admin dashboard
+func RunFoodRevenueBackfill(c *gin.Context) {
+	start := c.Param("start")
+	end := c.Param("end")
+
+	if err := BackfillFoodRevenue(start, end); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	days, err := rolledUpFoodRevenue(DB, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch rebuilt food revenue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Food revenue rebuilt successfully",
+		"days":    days,
+	})
+}
+
+// CheckFoodRevenue compares the rollup with totals recomputed from the orders and lists the days that differ
+func CheckFoodRevenue(c *gin.Context) {
+	start := c.Param("start")
+	end := c.Param("end")
+
+	if _, err := time.Parse("2006-01-02", start); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start date format. Use YYYY-MM-DD"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", end); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	rolledUp, err := rolledUpFoodRevenue(DB, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch food revenue rollup"})
+		return
+	}
+	raw, err := foodRevenueByType(DB, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate food revenue from orders"})
+		return
+	}
+
+	rollupByDate := make(map[string]foodRevenueBreakdown)
+	for _, day := range rolledUp {
+		rollupByDate[day.Date] = day
+	}
+	rawByDate := make(map[string]foodRevenueBreakdown)
+	for _, day := range raw {
+		rawByDate[day.Date] = day
+	}
+
+	type mismatch struct {
+		Date     string               `json:"date"`
+		Rollup   foodRevenueBreakdown `json:"rollup"`
+		Orders   foodRevenueBreakdown `json:"orders"`
+		Variance Money                `json:"variance"`
+	}
+	mismatches := []mismatch{}
+	for date := range mergeKeys(rollupByDate, rawByDate) {
+		rollup, orders := rollupByDate[date], rawByDate[date]
+		if rollup.RoomService.Minor != orders.RoomService.Minor || rollup.WalkIn.Minor != orders.WalkIn.Minor {
+			mismatches = append(mismatches, mismatch{
+				Date:     date,
+				Rollup:   rollup,
+				Orders:   orders,
+				Variance: rollup.Total.Sub(orders.Total),
+			})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Date < mismatches[j].Date
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"consistent": len(mismatches) == 0,
+		"mismatches": mismatches,
+	})
+}
+
+func mergeKeys(a, b map[string]foodRevenueBreakdown) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
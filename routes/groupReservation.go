@@ -0,0 +1,463 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GroupReservation struct {
+	ID              int         `gorm:"primaryKey;autoIncrement"`
+	GroupName       string      `gorm:"not null"`
+	ContactName     string      `gorm:"not null"`
+	NationalID      *string     `gorm:"null"`
+	Phone           *string     `gorm:"null"`
+	CheckinDate     time.Time   `gorm:"type:date;not null"`
+	CheckoutDate    time.Time   `gorm:"type:date;not null"`
+	ReservationDate time.Time   `gorm:"type:date;not null"`
+	Status          string      `gorm:"type:enum('CONFIRMED','CHECKED-IN','CHECKED-OUT','CANCELLED');default:'CONFIRMED'"`
+	BillingMode     string      `gorm:"type:enum('SHARED','SPLIT');default:'SHARED'"` // SHARED bills the organiser, SPLIT bills each room
+	PaymentType     string      `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');default:'NONE'"`
+	AmountPaid      *Money      `gorm:"null"`
+	Notes           *string     `gorm:"type:text;null"`
+	Rooms           []GroupRoom `gorm:"foreignKey:GroupReservationID"`
+}
+
+type GroupRoom struct {
+	ID                 int     `gorm:"primaryKey;autoIncrement"`
+	GroupReservationID int     `gorm:"not null;index"`
+	GuestName          string  `gorm:"not null"`
+	NationalID         *string `gorm:"null"`
+	Phone              *string `gorm:"null"`
+	RoomType           string  `gorm:"type:enum('FULL-NIGHT','DAY-CAUTION','SESSION');default:'FULL-NIGHT';not null"`
+	ExtraBed           bool    `gorm:"default:false"`
+	RoomNumber         *int    `gorm:"null"`
+	GuestID            *int    `gorm:"null"`
+	Status             string  `gorm:"type:enum('RESERVED','CHECKED-IN','CHECKED-OUT','CANCELLED');default:'RESERVED'"`
+}
+
+type groupRoomBill struct {
+	GroupRoomID  int    `json:"groupRoomId"`
+	GuestID      int    `json:"guestId"`
+	GuestName    string `json:"guestName"`
+	RoomNumber   int    `json:"roomNumber"`
+	RoomCharges  Money  `json:"roomCharges"`
+	FoodCharges  Money  `json:"foodCharges"`
+	ExtraCharges Money  `json:"extraCharges"`
+	Tax          Money  `json:"tax"` // Service charge and commercial tax added on top of prices
+	Discount     Money  `json:"discount"`
+	AmountPaid   Money  `json:"amountPaid"`
+	Total        Money  `json:"total"`
+	Balance      Money  `json:"balance"`
+}
+
+// roomStatusForType maps a booking type to the occupied room status shown on the dashboard
+func roomStatusForType(roomType string) int {
+	switch roomType {
+	case "DAY-CAUTION":
+		return 3
+	case "SESSION":
+		return 4
+	default:
+		return 2
+	}
+}
+
+func CreateGroupReservation(c *gin.Context) {
+	var group GroupReservation
+
+	if err := c.BindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if len(group.Rooms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A group reservation needs at least one room"})
+		return
+	}
+
+	for _, room := range group.Rooms {
+		if room.GuestName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Every room needs a guest name"})
+			return
+		}
+	}
+
+	if group.ReservationDate.IsZero() {
+		group.ReservationDate = time.Now().UTC()
+	}
+
+	if err := DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create group reservation: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Group reservation created successfully",
+		"reservation": group,
+	})
+}
+
+func GetGroupReservation(c *gin.Context) {
+	id := c.Param("id")
+
+	var group GroupReservation
+	if err := DB.Preload("Rooms").First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func GetGroupReservationsByDate(c *gin.Context) {
+	date := c.Param("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid date format"})
+		return
+	}
+
+	var groups []GroupReservation
+	if err := DB.Preload("Rooms").Where("DATE(checkin_date) = ?", date).Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch group reservations"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+func CancelGroupReservation(c *gin.Context) {
+	id := c.Param("id")
+
+	var group GroupReservation
+	if err := DB.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if group.Status != "CONFIRMED" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Only confirmed group reservations can be cancelled"})
+		return
+	}
+
+	tx := DB.Begin()
+	if err := tx.Model(&group).Update("status", "CANCELLED").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to cancel group reservation"})
+		return
+	}
+	if err := tx.Model(&GroupRoom{}).Where("group_reservation_id = ?", group.ID).Update("status", "CANCELLED").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to cancel group rooms"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group reservation cancelled successfully"})
+}
+
+// GroupCheckIn checks in every reserved room of the group, optionally assigning room numbers first
+func GroupCheckIn(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		Assignments []struct {
+			GroupRoomID int
+			RoomNumber  int
+		}
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var group GroupReservation
+	if err := tx.Preload("Rooms").First(&group, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if group.Status == "CANCELLED" || group.Status == "CHECKED-OUT" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Group reservation is " + group.Status})
+		return
+	}
+
+	assignments := make(map[int]int)
+	for _, assignment := range request.Assignments {
+		assignments[assignment.GroupRoomID] = assignment.RoomNumber
+	}
+
+	now := time.Now().UTC()
+	checkedIn := 0
+	for _, groupRoom := range group.Rooms {
+		if groupRoom.Status != "RESERVED" {
+			continue
+		}
+
+		if roomNumber, ok := assignments[groupRoom.ID]; ok {
+			groupRoom.RoomNumber = &roomNumber
+		}
+		if groupRoom.RoomNumber == nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("No room assigned for %s", groupRoom.GuestName)})
+			return
+		}
+
+		var room Rooms
+		if err := tx.Where("room = ? AND status = ?", strconv.Itoa(*groupRoom.RoomNumber), 1).First(&room).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Room %d is not available", *groupRoom.RoomNumber)})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check room status"})
+			}
+			return
+		}
+
+		profile, err := findOrCreateProfile(tx, groupRoom.GuestName, groupRoom.NationalID, groupRoom.Phone)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, ErrGuestBlacklisted) {
+				c.JSON(http.StatusForbidden, gin.H{"message": fmt.Sprintf("%s is blacklisted", groupRoom.GuestName)})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to look up guest profile"})
+			return
+		}
+
+		guest := Guests{
+			Name:               groupRoom.GuestName,
+			NationalID:         groupRoom.NationalID,
+			Phone:              groupRoom.Phone,
+			RoomType:           groupRoom.RoomType,
+			RoomNumber:         *groupRoom.RoomNumber,
+			CheckinDate:        now,
+			CheckoutDate:       group.CheckoutDate,
+			ExtraBed:           groupRoom.ExtraBed,
+			GroupReservationID: &group.ID,
+		}
+		if profile != nil {
+			guest.ProfileID = &profile.ID
+		}
+
+		if err := validateCheckinDocuments(&guest); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("%s: %s", groupRoom.GuestName, err.Error())})
+			return
+		}
+		for i := range guest.Documents {
+			guest.Documents[i].ProfileID = guest.ProfileID
+		}
+		if err := tx.Create(&guest).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create guest"})
+			return
+		}
+
+		if err := tx.Model(&room).Update("status", roomStatusForType(groupRoom.RoomType)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update room status"})
+			return
+		}
+
+		if err := tx.Model(&groupRoom).Updates(map[string]interface{}{
+			"room_number": *groupRoom.RoomNumber,
+			"guest_id":    guest.ID,
+			"status":      "CHECKED-IN",
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update group room"})
+			return
+		}
+		checkedIn++
+	}
+
+	if checkedIn == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "No reserved rooms left to check in"})
+		return
+	}
+
+	if err := tx.Model(&group).Update("status", "CHECKED-IN").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update group reservation"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Group checked in successfully",
+		"checkedIn": checkedIn,
+	})
+}
+
+// GroupCheckOut checks out every in-house room of the group and returns the final bill
+func GroupCheckOut(c *gin.Context) {
+	id := c.Param("id")
+
+	tx := DB.Begin()
+
+	var group GroupReservation
+	if err := tx.Preload("Rooms").First(&group, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if group.Status != "CHECKED-IN" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Group is not checked in"})
+		return
+	}
+
+	for _, groupRoom := range group.Rooms {
+		if groupRoom.Status != "CHECKED-IN" || groupRoom.GuestID == nil {
+			continue
+		}
+
+		if err := tx.Model(&Guests{}).Where("id = ?", *groupRoom.GuestID).Update("status", "CHECKED-OUT").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check out guest"})
+			return
+		}
+
+		// Room goes to housekeeping after checkout
+		if err := tx.Model(&Rooms{}).Where("room = ?", strconv.Itoa(*groupRoom.RoomNumber)).Update("status", 5).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update room status"})
+			return
+		}
+
+		if err := tx.Model(&groupRoom).Update("status", "CHECKED-OUT").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update group room"})
+			return
+		}
+	}
+
+	if err := tx.Model(&group).Update("status", "CHECKED-OUT").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update group reservation"})
+		return
+	}
+
+	bill, err := buildGroupBill(tx, group)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build group bill"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Group checked out successfully",
+		"bill":    bill,
+	})
+}
+
+func GetGroupBill(c *gin.Context) {
+	id := c.Param("id")
+
+	var group GroupReservation
+	if err := DB.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Group reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	bill, err := buildGroupBill(DB, group)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build group bill"})
+		return
+	}
+	c.JSON(http.StatusOK, bill)
+}
+
+// buildGroupBill totals the charges of every stay in the group, as one bill or one per room depending on BillingMode
+func buildGroupBill(tx *gorm.DB, group GroupReservation) (gin.H, error) {
+	var guests []Guests
+	if err := tx.Where("group_reservation_id = ?", group.ID).Find(&guests).Error; err != nil {
+		return nil, err
+	}
+
+	var groupRooms []GroupRoom
+	if err := tx.Where("group_reservation_id = ?", group.ID).Find(&groupRooms).Error; err != nil {
+		return nil, err
+	}
+	groupRoomByGuest := make(map[int]int)
+	for _, groupRoom := range groupRooms {
+		if groupRoom.GuestID != nil {
+			groupRoomByGuest[*groupRoom.GuestID] = groupRoom.ID
+		}
+	}
+
+	rooms := []groupRoomBill{}
+	var total, paid Money
+	for _, guest := range guests {
+		line := groupRoomBill{
+			GroupRoomID:  groupRoomByGuest[guest.ID],
+			GuestID:      guest.ID,
+			GuestName:    guest.Name,
+			RoomNumber:   guest.RoomNumber,
+			RoomCharges:  guest.RoomCharges,
+			FoodCharges:  guest.FoodCharges,
+			ExtraCharges: guest.ExtraCharges,
+			Tax:          guest.ServiceCharges.Add(guest.TaxCharges),
+			Discount:     guest.LoyaltyDiscount,
+		}
+		if guest.AmountPaid != nil {
+			line.AmountPaid = *guest.AmountPaid
+		}
+		line.Total = line.RoomCharges.Add(line.FoodCharges).Add(line.ExtraCharges).Add(line.Tax).Sub(line.Discount)
+		line.Balance = line.Total.Sub(line.AmountPaid)
+		total = total.Add(line.Total)
+		paid = paid.Add(line.AmountPaid)
+		rooms = append(rooms, line)
+	}
+
+	// Deposits taken against the group itself count towards the shared bill
+	if group.AmountPaid != nil {
+		paid = paid.Add(*group.AmountPaid)
+	}
+
+	bill := gin.H{
+		"groupReservationId": group.ID,
+		"groupName":          group.GroupName,
+		"billingMode":        group.BillingMode,
+		"total":              total,
+		"amountPaid":         paid,
+		"balance":            total.Sub(paid),
+	}
+	if group.BillingMode == "SPLIT" {
+		bill["rooms"] = rooms
+	} else {
+		bill["billTo"] = group.ContactName
+		bill["lines"] = rooms
+	}
+	return bill, nil
+}
@@ -0,0 +1,211 @@
+package routes
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrGuestBlacklisted is returned when a stay or booking is attempted for a blacklisted guest profile
+var ErrGuestBlacklisted = errors.New("guest is blacklisted")
+
+type GuestProfile struct {
+	ID              int       `gorm:"primaryKey;autoIncrement"`
+	Name            string    `gorm:"not null"`
+	NationalID      *string   `gorm:"null;index"`
+	Phone           *string   `gorm:"null;index"`
+	Email           *string   `gorm:"null"`
+	Notes           *string   `gorm:"type:text;null"`
+	Preferences     *string   `gorm:"type:text;null"`
+	Blacklisted     bool      `gorm:"default:false"`
+	BlacklistReason *string   `gorm:"type:text;null"`
+	LoyaltyPoints   int       `gorm:"not null;default:0"`
+	LifetimePoints  int       `gorm:"not null;default:0"`
+	LoyaltyTier     string    `gorm:"type:varchar(20);default:'BASIC'"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// normalizeIdentifier trims an optional identifier, treating blank values as absent
+func normalizeIdentifier(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// findOrCreateProfile returns the profile matching the national ID or phone, creating one for a new guest.
+// A guest with neither identifier can't be matched later, so no profile is created and nil is returned.
+func findOrCreateProfile(tx *gorm.DB, name string, nationalID, phone *string) (*GuestProfile, error) {
+	nationalID = normalizeIdentifier(nationalID)
+	phone = normalizeIdentifier(phone)
+	if nationalID == nil && phone == nil {
+		return nil, nil
+	}
+
+	var profile GuestProfile
+	err := gorm.ErrRecordNotFound
+	if nationalID != nil {
+		err = tx.Where("national_id = ?", *nationalID).First(&profile).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && phone != nil {
+		err = tx.Where("phone = ?", *phone).First(&profile).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		profile = GuestProfile{
+			Name:       name,
+			NationalID: nationalID,
+			Phone:      phone,
+		}
+		if err := tx.Create(&profile).Error; err != nil {
+			return nil, err
+		}
+		return &profile, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Blacklisted {
+		return &profile, ErrGuestBlacklisted
+	}
+
+	// Fill in whichever identifier the profile was missing
+	updates := map[string]interface{}{}
+	if profile.NationalID == nil && nationalID != nil {
+		updates["national_id"] = *nationalID
+	}
+	if profile.Phone == nil && phone != nil {
+		updates["phone"] = *phone
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&profile).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &profile, nil
+}
+
+func SearchProfiles(c *gin.Context) {
+	searchTerm := c.Query("term")
+	var profiles []GuestProfile
+
+	query := DB.Order("updated_at DESC").Limit(50)
+	if searchTerm != "" {
+		like := "%" + searchTerm + "%"
+		query = query.Where("name LIKE ? OR phone LIKE ? OR national_id LIKE ?", like, like, like)
+	}
+
+	if err := query.Find(&profiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to search guest profiles"})
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
+func GetProfile(c *gin.Context) {
+	id := c.Param("id")
+
+	var profile GuestProfile
+	if err := DB.First(&profile, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest profile not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var stays []Guests
+	if err := DB.Where("profile_id = ?", profile.ID).Order("checkin_date DESC").Find(&stays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch stay history"})
+		return
+	}
+
+	var reservations []Reservation
+	if err := DB.Where("profile_id = ?", profile.ID).Order("checkin_date DESC").Find(&reservations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reservations"})
+		return
+	}
+
+	var lifetimeSpend Money
+	if err := DB.Model(&Income{}).
+		Where("guest_id IN (?)", DB.Model(&Guests{}).Select("id").Where("profile_id = ?", profile.ID)).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&lifetimeSpend).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate lifetime spend"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile":       profile,
+		"stays":         stays,
+		"reservations":  reservations,
+		"totalStays":    len(stays),
+		"lifetimeSpend": lifetimeSpend,
+	})
+}
+
+func UpdateProfile(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		Name            *string
+		Email           *string
+		Notes           *string
+		Preferences     *string
+		Blacklisted     *bool
+		BlacklistReason *string
+	}
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var profile GuestProfile
+	if err := DB.First(&profile, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest profile not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if request.Name != nil {
+		updates["name"] = *request.Name
+	}
+	if request.Email != nil {
+		updates["email"] = *request.Email
+	}
+	if request.Notes != nil {
+		updates["notes"] = *request.Notes
+	}
+	if request.Preferences != nil {
+		updates["preferences"] = *request.Preferences
+	}
+	if request.Blacklisted != nil {
+		updates["blacklisted"] = *request.Blacklisted
+	}
+	if request.BlacklistReason != nil {
+		updates["blacklist_reason"] = *request.BlacklistReason
+	}
+
+	if len(updates) > 0 {
+		if err := DB.Model(&profile).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, profile)
+}
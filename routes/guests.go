@@ -6,25 +6,96 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type Guests struct {
-	ID           int       `gorm:"primaryKey;autoIncrement"`
-	Name         string    `gorm:"not null"`
-	NationalID   *string   `gorm:"null"`
-	Phone        *string   `gorm:"null"`
-	RoomType     string    `gorm:"type:enum('FULL-NIGHT','DAY-CAUTION','SESSION');default:'FULL-NIGHT';not null"`
-	RoomNumber   int       `gorm:"not null"`
-	CheckinDate  time.Time `gorm:"type:datetime;not null"`
-	CheckoutDate time.Time `gorm:"type:datetime;not null"`
-	ExtraBed     bool      `gorm:"default:false"`
-	PaymentType  string    `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');default:'NONE'"`
-	AmountPaid   *int      `gorm:"null"`
-	ExtraCharges int       `gorm:"not null; default:0"`
-	FoodCharges  int       `gorm:"not null; default:0"`
-	Paid         bool      `gorm:"default:false"`
-	Status       string    `gorm:"type:enum('ACTIVE', 'CHECKED-OUT'); default:'ACTIVE'"`
+	ID                 int                `gorm:"primaryKey;autoIncrement"`
+	Name               string             `gorm:"not null"`
+	NationalID         *string            `gorm:"null"`
+	Phone              *string            `gorm:"null"`
+	RoomType           string             `gorm:"type:enum('FULL-NIGHT','DAY-CAUTION','SESSION');default:'FULL-NIGHT';not null"`
+	RoomNumber         int                `gorm:"not null"`
+	CheckinDate        time.Time          `gorm:"type:datetime;not null"`
+	CheckoutDate       time.Time          `gorm:"type:datetime;not null"`
+	ExtraBed           bool               `gorm:"default:false"`
+	PaymentType        string             `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');default:'NONE'"`
+	AmountPaid         *Money             `gorm:"null"`
+	RoomCharges        Money              `gorm:"not null; default:0"`
+	ExtraCharges       Money              `gorm:"not null; default:0"`
+	FoodCharges        Money              `gorm:"not null; default:0"`
+	ServiceCharges     Money              `gorm:"not null; default:0"` // Service charge added on top of prices
+	TaxCharges         Money              `gorm:"not null; default:0"` // Commercial tax added on top of prices
+	LoyaltyDiscount    Money              `gorm:"not null; default:0"`
+	Paid               bool               `gorm:"default:false"`
+	Status             string             `gorm:"type:enum('ACTIVE', 'CHECKED-OUT'); default:'ACTIVE'"`
+	GroupReservationID *int               `gorm:"null;index"`
+	ReservationID      *int               `gorm:"null;index"` // Reservation the stay was checked in from
+	ProfileID          *int               `gorm:"null;index"`
+	Documents          []IdentityDocument `gorm:"foreignKey:GuestID"`
+}
+
+// ErrAmountPaidReduced is returned when a screen sends a stay's amount paid as less than its payments add up to
+var ErrAmountPaidReduced = errors.New("amount paid can't be reduced")
+
+// paymentMethodOr is the payment method a screen chose for a stay, or cash when it chose none
+func paymentMethodOr(method string) string {
+	if !paymentMethods[method] {
+		return "CASH"
+	}
+	return method
+}
+
+// topUpAmountPaid records the difference as a payment when a screen that still edits a stay's amount paid
+// sends more than has been paid so far. Money is only given back through a refund.
+func topUpAmountPaid(tx *gorm.DB, guest Guests, amountPaid Money, incomeType string, takenBy *int) error {
+	_, paid, err := stayBalance(tx, guest)
+	if err != nil {
+		return err
+	}
+	if amountPaid.Minor < paid.Minor {
+		return ErrAmountPaidReduced
+	}
+	if amountPaid.Minor == paid.Minor {
+		return nil
+	}
+	payment := Payment{
+		GuestID: &guest.ID,
+		Amount:  Money{Minor: amountPaid.Minor - paid.Minor, Currency: baseCurrency},
+		Method:  paymentMethodOr(guest.PaymentType),
+		TakenBy: takenBy,
+	}
+	return recordPayment(tx, &payment, incomeType, guest.RoomNumber)
+}
+
+// chargeFolio books a change in one type of charge entered directly on a stay's folio, at check-in or by
+// editing the stay. Room and food charges carry service charge and commercial tax like any other charge
+// of their type, and other charges are untaxed. before is what the stay's charges of that type stood at.
+func chargeFolio(tx *gorm.DB, guest Guests, chargeType string, before, delta Money, description string) error {
+	if delta.IsZero() {
+		return nil
+	}
+	guestID := uint(guest.ID)
+	description = fmt.Sprintf("Room %d %s", guest.RoomNumber, description)
+	if chargeType == "OTHER" {
+		return postFolioCharge(tx, "GUEST", guestID, accountOtherRevenue, untaxed(delta), description)
+	}
+
+	lines := tx.Model(&TaxLine{}).Where("guest_id = ? AND charge_type = ?", guest.ID, chargeType)
+	splits, err := changeTaxSplits(tx, lines, chargeType, before, delta)
+	if err != nil {
+		return err
+	}
+	for _, split := range splits {
+		if err := recordTax(tx, &guest.ID, chargeType, "GUEST", guestID, time.Time{}, split); err != nil {
+			return err
+		}
+		if err := postFolioCharge(tx, "GUEST", guestID, revenueAccountFor(strings.ToLower(chargeType)), split, description); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func CreateGuest(c *gin.Context) {
@@ -39,11 +110,106 @@ func CreateGuest(c *gin.Context) {
 	// No need to set CheckinDate here since it's already set in frontend
 	// with exact Myanmar time down to seconds
 
-	if err := DB.Create(&guest).Error; err != nil {
+	// Money taken at check-in is recorded as a payment, and the amount paid is then kept in step with
+	// the stay's payments
+	amountPaid := guest.AmountPaid
+	guest.AmountPaid = nil
+
+	if err := validateCheckinDocuments(&guest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := DB.Begin()
+
+	// Link the stay to the returning guest's profile, or start a new one
+	profile, err := findOrCreateProfile(tx, guest.Name, guest.NationalID, guest.Phone)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrGuestBlacklisted) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Guest is blacklisted", "profile": profile})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to look up guest profile"})
+		return
+	}
+	if profile != nil {
+		guest.ProfileID = &profile.ID
+		for i := range guest.Documents {
+			guest.Documents[i].ProfileID = &profile.ID
+		}
+	}
+
+	// Service charge and tax are added as the charges are posted below
+	guest.ServiceCharges, guest.TaxCharges = Money{}, Money{}
+	if err := tx.Create(&guest).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create guest"})
 		return
 	}
 
+	for _, charge := range []struct {
+		chargeType string
+		amount     Money
+	}{{"ROOM", guest.RoomCharges}, {"FOOD", guest.FoodCharges}, {"OTHER", guest.ExtraCharges}} {
+		if err := chargeFolio(tx, guest, charge.chargeType, Money{}, charge.amount, "check-in charges"); err != nil {
+			tx.Rollback()
+			fmt.Printf("Error posting check-in charges to the ledger: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post charges to the ledger"})
+			return
+		}
+	}
+
+	if guest.ReservationID != nil {
+		var reservation Reservation
+		if err := tx.First(&reservation, *guest.ReservationID).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Reservation not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reservation"})
+			return
+		}
+		if reservation.Status != "CONFIRMED" {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Reservation is " + reservation.Status})
+			return
+		}
+		if err := transferDeposits(tx, reservation.ID, guest); err != nil {
+			tx.Rollback()
+			fmt.Printf("Error moving reservation deposits to the stay: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to move reservation deposits"})
+			return
+		}
+		if err := tx.Model(&reservation).Update("status", "CHECKED-IN").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update reservation"})
+			return
+		}
+	}
+
+	if amountPaid != nil && amountPaid.Sign() > 0 {
+		payment := Payment{
+			GuestID: &guest.ID,
+			Amount:  *amountPaid,
+			Method:  paymentMethodOr(guest.PaymentType),
+			TakenBy: receptionistID(c),
+		}
+		if err := recordPayment(tx, &payment, "room", guest.RoomNumber); err != nil {
+			tx.Rollback()
+			respondPaymentError(c, err, "Failed to record payment")
+			return
+		}
+	}
+
+	if err := tx.First(&guest, guest.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create guest"})
+		return
+	}
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Guest created successfully",
 		"guest":   guest,
@@ -87,6 +253,12 @@ func UpdateGuestInfo(c *gin.Context) {
 		return
 	}
 
+	// The amount paid follows the stay's payments; more than has been paid so far is taken as a payment.
+	// Service charge and tax follow the charges they are on.
+	amountPaid := guest.AmountPaid
+	guest.AmountPaid = nil
+	guest.ServiceCharges, guest.TaxCharges = Money{}, Money{}
+
 	var existingGuest Guests
 	if err := DB.First(&existingGuest, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -97,10 +269,54 @@ func UpdateGuestInfo(c *gin.Context) {
 		return
 	}
 
-	if err := DB.Model(&existingGuest).Updates(guest).Error; err != nil {
+	before := existingGuest
+	tx := DB.Begin()
+	if err := tx.Model(&existingGuest).Omit("Documents").Updates(guest).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+
+	// Charges edited on the stay are posted as the difference from what was there before. Updates skips
+	// zero fields, so only the charges that were sent have changed.
+	changed := func(value, previous Money) Money {
+		if value.IsZero() {
+			return Money{}
+		}
+		return value.Sub(previous)
+	}
+	for _, charge := range []struct {
+		chargeType    string
+		before, delta Money
+	}{
+		{"ROOM", before.RoomCharges, changed(guest.RoomCharges, before.RoomCharges)},
+		{"FOOD", before.FoodCharges, changed(guest.FoodCharges, before.FoodCharges)},
+		{"OTHER", before.ExtraCharges, changed(guest.ExtraCharges, before.ExtraCharges)},
+	} {
+		if err := chargeFolio(tx, before, charge.chargeType, charge.before, charge.delta, "folio adjustment"); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post charges to the ledger"})
+			return
+		}
+	}
+
+	if amountPaid != nil {
+		if err := topUpAmountPaid(tx, existingGuest, *amountPaid, "room", receptionistID(c)); err != nil {
+			tx.Rollback()
+			if errors.Is(err, ErrAmountPaidReduced) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Amount paid can't be reduced here"})
+				return
+			}
+			respondPaymentError(c, err, "Failed to record payment")
+			return
+		}
+	}
+	if err := tx.First(&existingGuest, existingGuest.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest"})
+		return
+	}
+	tx.Commit()
 	c.JSON(http.StatusOK, existingGuest)
 }
 
@@ -124,8 +340,8 @@ func UpdateGuestFoodPrice(c *gin.Context) {
 	}
 
 	var requestBody struct {
-		FoodCharges float64 `json:"foodCharges"`
-		AmountPaid  float64 `json:"amountPaid"`
+		FoodCharges Money `json:"foodCharges"`
+		AmountPaid  Money `json:"amountPaid"`
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -133,16 +349,38 @@ func UpdateGuestFoodPrice(c *gin.Context) {
 		return
 	}
 
-	// Update both food charges and amount paid
-	updates := map[string]interface{}{
-		"food_charges": requestBody.FoodCharges,
-		"amount_paid":  requestBody.AmountPaid,
+	tx := DB.Begin()
+
+	foodCharges := guest.FoodCharges
+	if err := tx.Model(&guest).Update("food_charges", requestBody.FoodCharges).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest"})
+		return
+	}
+	if err := chargeFolio(tx, guest, "FOOD", foodCharges, requestBody.FoodCharges.Sub(foodCharges), "food charges adjustment"); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post charges to the ledger"})
+		return
+	}
+
+	// Amount paid is now the sum of the stay's payments, so an increase from this screen is recorded as a
+	// payment, booked as food income
+	if err := topUpAmountPaid(tx, guest, requestBody.AmountPaid, "food", receptionistID(c)); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrAmountPaidReduced) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Amount paid can't be reduced here"})
+			return
+		}
+		respondPaymentError(c, err, "Failed to update guest")
+		return
 	}
 
-	if err := DB.Model(&guest).Updates(updates).Error; err != nil {
+	if err := tx.First(&guest, guest.ID).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest"})
 		return
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Guest updated successfully",
@@ -0,0 +1,283 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type IdentityDocument struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement"`
+	GuestID       *int       `gorm:"null;index"`
+	ReservationID *int       `gorm:"null;index"`
+	ProfileID     *int       `gorm:"null;index"`
+	DocumentType  string     `gorm:"type:enum('NRC','PASSPORT','DRIVING_LICENCE');not null"`
+	Number        string     `gorm:"type:varchar(50);not null"`
+	Nationality   string     `gorm:"type:varchar(100);default:'MYANMAR'"`
+	ExpiryDate    *time.Time `gorm:"type:date;null"`
+	ScanPath      *string    `gorm:"null" json:"-"` // Only served to admins through GetDocumentScan
+	HasScan       bool       `gorm:"-"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+}
+
+func (document *IdentityDocument) AfterFind(tx *gorm.DB) error {
+	document.HasScan = document.ScanPath != nil
+	return nil
+}
+
+// Guests must show a valid document at check-in. Turning this off only skips the requirement; documents
+// that are supplied are still validated.
+var requireIDAtCheckin = true
+
+const (
+	uploadDir          = "uploads"
+	maxUploadSize      = 10 << 20 // 10 MB
+	identityScanSubdir = "id-scans"
+)
+
+var (
+	// Myanmar NRC in English form, e.g. 12/LaMaNa(N)123456
+	nrcPattern = regexp.MustCompile(`^(1[0-4]|[1-9])/[A-Za-z]{3,9}\((N|E|P|T|NAING|AE|PYU|THA)\)[0-9]{6}$`)
+	// Myanmar passports are two letters and six or seven digits, e.g. MA123456
+	myanmarPassportPattern = regexp.MustCompile(`^M[A-Z][0-9]{6,7}$`)
+	// Other passports follow ICAO 9303: up to nine letters or digits
+	passportPattern       = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+	drivingLicencePattern = regexp.MustCompile(`^[A-Z0-9/\-]{5,20}$`)
+)
+
+// isMyanmarNational treats a blank nationality as Myanmar, the hotel's default
+func isMyanmarNational(nationality string) bool {
+	switch strings.ToUpper(strings.TrimSpace(nationality)) {
+	case "", "MYANMAR", "MM", "MMR", "BURMESE":
+		return true
+	}
+	return false
+}
+
+// validateDocument normalizes the document number and checks it against the format of its type
+func validateDocument(document *IdentityDocument) error {
+	document.Number = strings.ToUpper(strings.TrimSpace(document.Number))
+	if document.Nationality == "" {
+		document.Nationality = "MYANMAR"
+	}
+
+	switch document.DocumentType {
+	case "NRC":
+		if !isMyanmarNational(document.Nationality) {
+			return errors.New("NRC can only be used by Myanmar nationals")
+		}
+		if !nrcPattern.MatchString(document.Number) {
+			return fmt.Errorf("invalid NRC number %q, expected a format like 12/LaMaNa(N)123456", document.Number)
+		}
+	case "PASSPORT":
+		pattern := passportPattern
+		if isMyanmarNational(document.Nationality) {
+			pattern = myanmarPassportPattern
+		}
+		if !pattern.MatchString(document.Number) {
+			return fmt.Errorf("invalid passport number %q", document.Number)
+		}
+		if document.ExpiryDate == nil {
+			return errors.New("passport expiry date is required")
+		}
+	case "DRIVING_LICENCE":
+		if !drivingLicencePattern.MatchString(document.Number) {
+			return fmt.Errorf("invalid driving licence number %q", document.Number)
+		}
+	default:
+		return fmt.Errorf("unsupported document type %q", document.DocumentType)
+	}
+	return nil
+}
+
+// validateCheckinDocuments applies the check-in rules: every document must be valid and unexpired,
+// at least one must be present, and foreign guests must show a passport. A legacy NationalID that is
+// a well-formed NRC counts as an NRC document.
+func validateCheckinDocuments(guest *Guests) error {
+	if len(guest.Documents) == 0 && guest.NationalID != nil {
+		number := strings.TrimSpace(*guest.NationalID)
+		if nrcPattern.MatchString(number) {
+			guest.Documents = append(guest.Documents, IdentityDocument{DocumentType: "NRC", Number: number})
+		}
+	}
+
+	if len(guest.Documents) == 0 {
+		if requireIDAtCheckin {
+			return errors.New("an identity document is required at check-in")
+		}
+		return nil
+	}
+
+	checkinDate := dateOnly(guest.CheckinDate)
+	if guest.CheckinDate.IsZero() {
+		checkinDate = dateOnly(time.Now().UTC())
+	}
+
+	hasPassport := false
+	foreign := false
+	for i := range guest.Documents {
+		document := &guest.Documents[i]
+		document.ID = 0
+		document.ScanPath = nil
+		if err := validateDocument(document); err != nil {
+			return err
+		}
+		if document.ExpiryDate != nil && dateOnly(*document.ExpiryDate).Before(checkinDate) {
+			return fmt.Errorf("%s %s expired on %s", document.DocumentType, document.Number, document.ExpiryDate.Format("2006-01-02"))
+		}
+		if document.DocumentType == "PASSPORT" {
+			hasPassport = true
+		}
+		if !isMyanmarNational(document.Nationality) {
+			foreign = true
+		}
+	}
+
+	if foreign && !hasPassport {
+		return errors.New("foreign guests must present a passport")
+	}
+	return nil
+}
+
+func GetGuestDocuments(c *gin.Context) {
+	guestID := c.Param("id")
+
+	var documents []IdentityDocument
+	if err := DB.Where("guest_id = ?", guestID).Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch documents"})
+		return
+	}
+	c.JSON(http.StatusOK, documents)
+}
+
+// AddGuestDocument attaches another identity document to an existing stay
+func AddGuestDocument(c *gin.Context) {
+	guestID := c.Param("id")
+
+	var guest Guests
+	if err := DB.First(&guest, guestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var document IdentityDocument
+	if err := c.BindJSON(&document); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := validateDocument(&document); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	document.ID = 0
+	document.GuestID = &guest.ID
+	document.ReservationID = nil
+	document.ProfileID = guest.ProfileID
+	document.ScanPath = nil
+	if err := DB.Create(&document).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save document"})
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// saveUpload stores a multipart file under uploads/<subdir> and returns its path on disk
+func saveUpload(c *gin.Context, field, subdir, prefix string, allowedExtensions ...string) (string, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		return "", fmt.Errorf("file %q is required", field)
+	}
+	if file.Size > maxUploadSize {
+		return "", errors.New("file is larger than 10 MB")
+	}
+
+	extension := strings.ToLower(filepath.Ext(file.Filename))
+	allowed := false
+	for _, allowedExtension := range allowedExtensions {
+		if extension == allowedExtension {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("file type %q is not allowed", extension)
+	}
+
+	dir := filepath.Join(uploadDir, subdir)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d%s", prefix, time.Now().UnixNano(), extension))
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func UploadDocumentScan(c *gin.Context) {
+	id := c.Param("id")
+
+	var document IdentityDocument
+	if err := DB.First(&document, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Document not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	path, err := saveUpload(c, "scan", identityScanSubdir, fmt.Sprintf("document-%d", document.ID), ".jpg", ".jpeg", ".png", ".pdf")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	previous := document.ScanPath
+	if err := DB.Model(&document).Update("scan_path", path).Error; err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save scan"})
+		return
+	}
+	if previous != nil {
+		os.Remove(*previous)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scan uploaded successfully"})
+}
+
+// GetDocumentScan serves the stored scan image; it is only registered on the admin group
+func GetDocumentScan(c *gin.Context) {
+	id := c.Param("id")
+
+	var document IdentityDocument
+	if err := DB.First(&document, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Document not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if document.ScanPath == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No scan uploaded for this document"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.File(*document.ScanPath)
+}
@@ -1,31 +1,90 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
 
 type Income struct {
-	ID            uint      `gorm:"primaryKey;autoIncrement"`
-	Type          string    `gorm:"column:type;type:varchar(255)"` // Changed to explicitly set column name and type
-	GuestID       *uint     `gorm:"default:null"`                  // Changed to pointer to make it optional
-	Guest         *Guests   `gorm:"foreignKey:GuestID"`            // Changed to pointer since it's optional
-	RoomNumber    int       `gorm:"default:0"`                     // Made default 0
-	Amount        float64   `gorm:"not null"`
-	RevenueType   string    `gorm:"column:revenue_type;type:varchar(50);default:'revenue'"` // Added revenue type field
-	PaymentMethod string    `gorm:"column:payment_method;type:varchar(50)"`                 // Add payment method field
-	CreatedAt     time.Time `gorm:"not null"`
+	ID             uint       `gorm:"primaryKey;autoIncrement"`
+	Type           string     `gorm:"column:type;type:varchar(255)"`                          // Changed to explicitly set column name and type
+	GuestID        *uint      `gorm:"default:null"`                                           // Changed to pointer to make it optional
+	Guest          *Guests    `gorm:"foreignKey:GuestID"`                                     // Changed to pointer since it's optional
+	RoomNumber     int        `gorm:"default:0"`                                              // Made default 0
+	Amount         Money      `gorm:"not null"`                                               // MMK equivalent at the time it was posted
+	Currency       string     `gorm:"type:varchar(3);not null;default:'MMK'"`                 // Currency the money was taken in
+	OriginalAmount Money      `gorm:"not null;default:0"`                                     // Amount in that currency
+	ExchangeRate   float64    `gorm:"not null;default:1"`                                     // MMK per unit it was converted at
+	RevenueType    string     `gorm:"column:revenue_type;type:varchar(50);default:'revenue'"` // Added revenue type field
+	PaymentMethod  string     `gorm:"column:payment_method;type:varchar(50)"`                 // Add payment method field
+	BusinessDate   *time.Time `gorm:"type:date"`                                              // Set from the night audit business date
+	ReversalOfID   *uint      `gorm:"null;index"`                                             // Original income a refund or void counter-entry reverses
+	ReceptionistID *int       `gorm:"null;index"`                                             // Receptionist who took the money
+	ShiftID        *uint      `gorm:"null;index"`                                             // Cashier shift it was taken on
+	FoodOrderID    *uint      `gorm:"null;index"`                                             // Walk-in order the money was taken or given back for
+	CreatedAt      time.Time  `gorm:"not null"`
+}
+
+// businessDate is the business date the income is booked to, falling back to its creation date for older rows
+func (income *Income) businessDate() time.Time {
+	if income.BusinessDate != nil {
+		return *income.BusinessDate
+	}
+	return income.CreatedAt
+}
+
+// originalShare is how much of the amount in the original currency a part of the MMK amount stands for,
+// so a partial refund gives back the same share of the dollars that were taken
+func (income *Income) originalShare(amount Money) Money {
+	if income.Currency == "" || income.Currency == baseCurrency {
+		return amount
+	}
+	return income.OriginalAmount.Share(amount, income.Amount)
+}
+
+func (income *Income) BeforeCreate(tx *gorm.DB) error {
+	if income.BusinessDate == nil {
+		businessDate, err := currentBusinessDate(hookDB(tx))
+		if err != nil {
+			return err
+		}
+		income.BusinessDate = &businessDate
+	}
+	if income.Currency == "" || income.Currency == baseCurrency {
+		income.Currency = baseCurrency
+		income.OriginalAmount = income.Amount
+		income.ExchangeRate = 1
+	}
+	income.OriginalAmount.Currency = income.Currency
+	return checkBusinessDateOpen(hookDB(tx), *income.BusinessDate)
+}
+
+// AfterFind labels the original amount with the currency it was taken in
+func (income *Income) AfterFind(tx *gorm.DB) error {
+	income.OriginalAmount.Currency = income.Currency
+	return nil
+}
+
+func (income *Income) BeforeUpdate(tx *gorm.DB) error {
+	return checkBusinessDateOpen(hookDB(tx), income.businessDate())
+}
+
+func (income *Income) BeforeDelete(tx *gorm.DB) error {
+	return checkBusinessDateOpen(hookDB(tx), income.businessDate())
 }
 
 type IncomeRequest struct {
-	Type          string  `json:"Type"`
-	GuestID       uint    `json:"GuestID"`
-	RoomNumber    int     `json:"RoomNumber"`
-	Amount        float64 `json:"Amount"`
-	RevenueType   string  `json:"RevenueType"`
-	PaymentMethod string  `json:"PaymentMethod"`
+	Type          string `json:"Type"`
+	GuestID       uint   `json:"GuestID"`
+	RoomNumber    int    `json:"RoomNumber"`
+	Amount        Money  `json:"Amount"`   // In Currency
+	Currency      string `json:"Currency"` // Defaults to MMK
+	RevenueType   string `json:"RevenueType"`
+	PaymentMethod string `json:"PaymentMethod"`
 }
 
 func AddIncome(c *gin.Context) {
@@ -43,14 +102,31 @@ func AddIncome(c *gin.Context) {
 		return
 	}
 
+	if req.Amount.Sign() <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Amount must be greater than zero"})
+		return
+	}
+
+	if !paymentMethods[req.PaymentMethod] {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Payment method must be one of CASH, KPAY, AYAPAY or WAVEPAY"})
+		return
+	}
+
+	if err := checkCurrency(DB, &req.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
 	// Create Income record with null GuestID if it's 0
 	income := Income{
-		Type:          req.Type,
-		RoomNumber:    req.RoomNumber,
-		Amount:        req.Amount,
-		RevenueType:   req.RevenueType,
-		PaymentMethod: req.PaymentMethod,
-		CreatedAt:     time.Now().UTC(),
+		Type:           req.Type,
+		RoomNumber:     req.RoomNumber,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		OriginalAmount: req.Amount.In(req.Currency),
+		RevenueType:    req.RevenueType,
+		PaymentMethod:  req.PaymentMethod,
+		CreatedAt:      time.Now().UTC(),
 	}
 
 	// Only set GuestID if it's not 0
@@ -59,12 +135,80 @@ func AddIncome(c *gin.Context) {
 		income.GuestID = &guestID
 	}
 
-	if err := DB.Create(&income).Error; err != nil {
+	tx := DB.Begin()
+
+	// Room charges, and food charged to a room, are booked on the stay's folio, so money taken for them
+	// settles the stay rather than being revenue a second time
+	if income.GuestID == nil && income.RoomNumber != 0 && (income.Type == "room" || income.Type == "food") {
+		var guest Guests
+		err := tx.Where("room_number = ? AND status = ?", income.RoomNumber, "ACTIVE").First(&guest).Error
+		if err == nil {
+			guestID := uint(guest.ID)
+			income.GuestID = &guestID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to find the stay for the room"})
+			return
+		}
+	}
+	if income.Type == "room" && income.GuestID == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Room income must be taken for a guest's stay"})
+		return
+	}
+
+	if income.Currency != baseCurrency {
+		businessDate, err := currentBusinessDate(tx)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch business date"})
+			return
+		}
+		if income.Amount, income.ExchangeRate, err = convertToMMK(tx, income.OriginalAmount, businessDate); err != nil {
+			tx.Rollback()
+			if errors.Is(err, ErrNoExchangeRate) {
+				c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to convert amount"})
+			return
+		}
+	}
+	if err := attributeIncome(tx, &income, receptionistID(c)); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrNoOpenShift) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Open a cashier shift before taking cash"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to find cashier shift"})
+		return
+	}
+	if err := tx.Create(&income).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrBusinessDateClosed) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Business date is closed"})
+			return
+		}
 		fmt.Printf("Error creating income: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": fmt.Sprintf("Failed to create income record: %v", err)})
 		return
 	}
 
+	if err := postIncomeJournal(tx, income, incomeCounterAccount(income)); err != nil {
+		tx.Rollback()
+		fmt.Printf("Error posting income to the ledger: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post income to the ledger"})
+		return
+	}
+
+	if err := accrueLoyaltyPoints(tx, income); err != nil {
+		tx.Rollback()
+		fmt.Printf("Error accruing loyalty points: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to accrue loyalty points"})
+		return
+	}
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Income recorded successfully.",
 		"income":  income,
@@ -0,0 +1,359 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type StockItem struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement"`
+	Name         string    `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Category     string    `gorm:"type:enum('KITCHEN','MINIBAR');not null;default:'KITCHEN'"`
+	Unit         string    `gorm:"not null"` // e.g. kg, litre, piece
+	Quantity     float64   `gorm:"not null;default:0"`
+	ReorderLevel float64   `gorm:"not null;default:0"` // Reported as low stock at or below this quantity
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+// RecipeIngredient is how much of a stock item goes into one serving of a menu item
+type RecipeIngredient struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	MenuID      uint       `gorm:"not null;index"`
+	StockItemID uint       `gorm:"not null"`
+	Quantity    float64    `gorm:"not null"`
+	StockItem   *StockItem `gorm:"foreignKey:StockItemID"`
+}
+
+// StockMovement records every change to a stock item's quantity; Quantity is negative for stock going out
+type StockMovement struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement"`
+	StockItemID     uint      `gorm:"not null;index"`
+	Type            string    `gorm:"type:enum('STOCK_IN','WASTAGE','SALE','ADJUSTMENT');not null"`
+	Quantity        float64   `gorm:"not null"`
+	FoodOrderID     *uint     `gorm:"index"`
+	AmenityChargeID *uint     `gorm:"index"`
+	Reason          *string   `gorm:"type:text"`
+	RecordedBy      *int      // Admin who entered it, empty for automatic deductions
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// adjustStock changes a stock item's quantity and records the movement that caused it
+func adjustStock(tx *gorm.DB, movement StockMovement) error {
+	result := tx.Model(&StockItem{}).
+		Where("id = ?", movement.StockItemID).
+		Update("quantity", gorm.Expr("quantity + ?", movement.Quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("stock item %d not found", movement.StockItemID)
+	}
+	return tx.Create(&movement).Error
+}
+
+// deductStockForOrder takes the ingredients of a delivered order out of stock. Stock is allowed to go
+// negative so a miscount never holds up delivery; the low-stock report shows it instead.
+func deductStockForOrder(tx *gorm.DB, order *FoodOrder) error {
+	var items []FoodOrderItem
+	if err := tx.Where("food_order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var ingredients []RecipeIngredient
+		if err := tx.Where("menu_id = ?", item.MenuID).Find(&ingredients).Error; err != nil {
+			return err
+		}
+		for _, ingredient := range ingredients {
+			orderID := order.ID
+			if err := adjustStock(tx, StockMovement{
+				StockItemID: ingredient.StockItemID,
+				Type:        "SALE",
+				Quantity:    -ingredient.Quantity * float64(item.Quantity),
+				FoodOrderID: &orderID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func GetStockItems(c *gin.Context) {
+	query := DB.Order("category, name")
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var items []StockItem
+	if err := query.Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch stock items"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func CreateStockItem(c *gin.Context) {
+	var item StockItem
+	if err := c.BindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" || item.Unit == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name and unit are required"})
+		return
+	}
+
+	// Opening stock goes through a movement so the history adds up to the current quantity
+	opening := item.Quantity
+	item.ID = 0
+	item.Quantity = 0
+
+	adminID := c.GetInt("user_id")
+	tx := DB.Begin()
+	if err := tx.Create(&item).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create stock item: " + err.Error()})
+		return
+	}
+	if opening != 0 {
+		reason := "Opening stock"
+		if err := adjustStock(tx, StockMovement{
+			StockItemID: item.ID,
+			Type:        "ADJUSTMENT",
+			Quantity:    opening,
+			Reason:      &reason,
+			RecordedBy:  &adminID,
+		}); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to record opening stock"})
+			return
+		}
+		item.Quantity = opening
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, item)
+}
+
+// UpdateStockItem edits an item's details; quantities only change through stock movements
+func UpdateStockItem(c *gin.Context) {
+	id := c.Param("id")
+
+	var item StockItem
+	if err := DB.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Stock item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var updateData struct {
+		Name         *string
+		Category     *string
+		Unit         *string
+		ReorderLevel *float64
+	}
+	if err := c.BindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if updateData.Name != nil {
+		updates["name"] = strings.TrimSpace(*updateData.Name)
+	}
+	if updateData.Category != nil {
+		updates["category"] = *updateData.Category
+	}
+	if updateData.Unit != nil {
+		updates["unit"] = *updateData.Unit
+	}
+	if updateData.ReorderLevel != nil {
+		updates["reorder_level"] = *updateData.ReorderLevel
+	}
+
+	if err := DB.Model(&item).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	DB.First(&item, item.ID)
+	c.JSON(http.StatusOK, item)
+}
+
+// recordStockMovement handles manual stock-in and wastage entries
+func recordStockMovement(c *gin.Context, movementType string) {
+	id := c.Param("id")
+
+	var item StockItem
+	if err := DB.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Stock item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var request struct {
+		Quantity float64
+		Reason   *string
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if request.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Quantity must be greater than zero"})
+		return
+	}
+	if movementType == "WASTAGE" && (request.Reason == nil || strings.TrimSpace(*request.Reason) == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A reason is required for wastage"})
+		return
+	}
+
+	quantity := request.Quantity
+	if movementType == "WASTAGE" {
+		quantity = -quantity
+	}
+
+	adminID := c.GetInt("user_id")
+	tx := DB.Begin()
+	if err := adjustStock(tx, StockMovement{
+		StockItemID: item.ID,
+		Type:        movementType,
+		Quantity:    quantity,
+		Reason:      request.Reason,
+		RecordedBy:  &adminID,
+	}); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to record stock movement"})
+		return
+	}
+	if err := tx.First(&item, item.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to record stock movement"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, item)
+}
+
+func StockIn(c *gin.Context) {
+	recordStockMovement(c, "STOCK_IN")
+}
+
+func RecordWastage(c *gin.Context) {
+	recordStockMovement(c, "WASTAGE")
+}
+
+func GetStockMovements(c *gin.Context) {
+	id := c.Param("id")
+
+	var movements []StockMovement
+	if err := DB.Where("stock_item_id = ?", id).Order("created_at DESC").Find(&movements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch stock movements"})
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
+
+// GetLowStockReport lists the items at or below their reorder level, most short first
+func GetLowStockReport(c *gin.Context) {
+	var items []StockItem
+	if err := DB.Where("quantity <= reorder_level").
+		Order("quantity - reorder_level ASC").
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch low stock report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"count": len(items),
+		"items": items,
+	})
+}
+
+func GetMenuRecipe(c *gin.Context) {
+	menuID := c.Param("id")
+
+	var ingredients []RecipeIngredient
+	if err := DB.Preload("StockItem").Where("menu_id = ?", menuID).Find(&ingredients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch recipe"})
+		return
+	}
+	c.JSON(http.StatusOK, ingredients)
+}
+
+// SetMenuRecipe replaces the ingredients used by one serving of a menu item
+func SetMenuRecipe(c *gin.Context) {
+	menuID := c.Param("id")
+
+	var menu Menu
+	if err := DB.First(&menu, menuID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Menu not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var request struct {
+		Ingredients []struct {
+			StockItemID uint
+			Quantity    float64
+		}
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	ingredients := make([]RecipeIngredient, 0, len(request.Ingredients))
+	for _, line := range request.Ingredients {
+		if line.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Ingredient quantities must be greater than zero"})
+			return
+		}
+		var count int64
+		DB.Model(&StockItem{}).Where("id = ?", line.StockItemID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Stock item %d not found", line.StockItemID)})
+			return
+		}
+		ingredients = append(ingredients, RecipeIngredient{
+			MenuID:      menu.ID,
+			StockItemID: line.StockItemID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	tx := DB.Begin()
+	if err := tx.Where("menu_id = ?", menu.ID).Delete(&RecipeIngredient{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update recipe"})
+		return
+	}
+	if len(ingredients) > 0 {
+		if err := tx.Create(&ingredients).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update recipe"})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, ingredients)
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidStatusTransition is returned when an order is moved to a state it can't reach from its current one
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// foodOrderTransitions lists the states each order state can move to
+var foodOrderTransitions = map[string][]string{
+	"PLACED":    {"ACCEPTED", "CANCELLED"},
+	"ACCEPTED":  {"PREPARING", "CANCELLED"},
+	"PREPARING": {"READY", "CANCELLED"},
+	"READY":     {"DELIVERED"},
+	"DELIVERED": {},
+	"CANCELLED": {},
+}
+
+// ErrPaidOrderNeedsDesk is returned when a paid walk-in order is cancelled by someone who can't give the
+// money back, such as the kitchen
+var ErrPaidOrderNeedsDesk = errors.New("a paid walk-in order has to be cancelled at the front desk")
+
+// Orders in these states are still the kitchen's to work on
+var kitchenQueueStatuses = []string{"PLACED", "ACCEPTED", "PREPARING", "READY"}
+
+func canTransitionFoodOrder(from, to string) bool {
+	for _, next := range foodOrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFoodOrderStatus moves the order to a new state and stamps the time it entered it. takenBy is the
+// receptionist giving the money back if a paid walk-in order is cancelled; without one, such an order
+// can't be cancelled.
+func applyFoodOrderStatus(tx *gorm.DB, order *FoodOrder, status string, takenBy *int) error {
+	if !canTransitionFoodOrder(order.Status, status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, order.Status, status)
+	}
+	if status == "CANCELLED" && order.OrderType == "WALK_IN" && order.IncomeID != nil && takenBy == nil {
+		return ErrPaidOrderNeedsDesk
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case "ACCEPTED":
+		updates["accepted_at"] = now
+	case "PREPARING":
+		updates["preparing_at"] = now
+	case "READY":
+		updates["ready_at"] = now
+	case "DELIVERED":
+		updates["delivered_at"] = now
+	case "CANCELLED":
+		updates["cancelled_at"] = now
+	}
+
+	if err := tx.Model(order).Updates(updates).Error; err != nil {
+		return err
+	}
+	order.Status = status
+
+	switch status {
+	case "DELIVERED":
+		// The ingredients are used up once the order leaves the kitchen
+		return deductStockForOrder(tx, order)
+	case "CANCELLED":
+		// A cancelled order is no longer owed, and a paid walk-in order is given back
+		return chargeFoodOrder(tx, order, order.Total.Neg(), takenBy)
+	}
+	return nil
+}
+
+// UpdateFoodOrderStatus moves an order through the kitchen workflow
+func UpdateFoodOrderStatus(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		Status string `json:"status"`
+	}
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	if _, ok := foodOrderTransitions[request.Status]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown order status " + request.Status})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var order FoodOrder
+	if err := tx.First(&order, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Food order not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch food order"})
+		return
+	}
+
+	if err := applyFoodOrderStatus(tx, &order, request.Status, nil); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to update order status")
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order status updated successfully",
+		"order":   order,
+	})
+}
+
+// GetKitchenQueue lists the orders the kitchen still has to work on, oldest first
+func GetKitchenQueue(c *gin.Context) {
+	var orders []FoodOrder
+
+	if err := DB.Preload("Items.Modifiers").
+		Where("status IN ?", kitchenQueueStatuses).
+		Order("order_time ASC").
+		Find(&orders).Error; err != nil {
+		fmt.Printf("Error fetching kitchen queue: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch kitchen queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
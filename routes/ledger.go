@@ -0,0 +1,754 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnbalancedEntry is returned when a journal entry's debits and credits don't match
+var ErrUnbalancedEntry = errors.New("journal entry debits and credits don't balance")
+
+// Account codes the system posts to automatically. They are created by the chart of accounts migration
+// and can be renamed but not deactivated.
+const (
+	accountCash          = "1000"
+	accountKPay          = "1010"
+	accountAYAPay        = "1020"
+	accountWavePay       = "1030"
+	accountBank          = "1040"
+	accountGuestLedger   = "1100" // What in-house and departed guests owe on their folios
+	accountGuestDeposits = "2100" // Payments taken on reservations before the guest arrives
+	accountTaxPayable    = "2200" // Commercial tax collected and not yet paid over
+	accountOwnersEquity  = "3000"
+	accountRoomRevenue   = "4000"
+	accountFoodRevenue   = "4100"
+	accountOtherRevenue  = "4200"
+	accountServiceCharge = "4300"
+	accountDiscounts     = "4900" // Loyalty discounts, shown as negative revenue
+	accountUtilities     = "5000"
+	accountSalaries      = "5100"
+	accountSupplies      = "5200"
+	accountMaintenance   = "5300"
+	accountFoodStock     = "5400"
+	accountOtherExpenses = "5900"
+)
+
+// Account is one line of the chart of accounts
+type Account struct {
+	ID     uint   `gorm:"primaryKey;autoIncrement"`
+	Code   string `gorm:"type:varchar(10);uniqueIndex;not null"`
+	Name   string `gorm:"not null"`
+	Type   string `gorm:"type:enum('ASSET','LIABILITY','EQUITY','REVENUE','EXPENSE');not null"`
+	System bool   `gorm:"not null;default:false"` // Posted to automatically
+	Active *bool  `gorm:"not null;default:true"`
+}
+
+// JournalEntry is one balanced posting to the general ledger. Entries are never edited; mistakes are
+// corrected with a reversing entry.
+type JournalEntry struct {
+	ID          uint          `gorm:"primaryKey;autoIncrement"`
+	Date        time.Time     `gorm:"type:date;not null;index"`
+	Description string        `gorm:"not null"`
+	SourceType  string        `gorm:"type:varchar(30);not null;index:idx_journal_source"` // INCOME, ROOM_CHARGE, FOOD_ORDER, AMENITY_CHARGE, GUEST, LOYALTY_DISCOUNT, EXPENSE or MANUAL
+	SourceID    *uint         `gorm:"null;index:idx_journal_source"`
+	PostedBy    *int          `gorm:"null"` // Admin who posted a manual entry
+	CreatedAt   time.Time     `gorm:"autoCreateTime"`
+	Lines       []JournalLine `gorm:"foreignKey:JournalEntryID"`
+}
+
+type JournalLine struct {
+	ID             uint     `gorm:"primaryKey;autoIncrement"`
+	JournalEntryID uint     `gorm:"not null;index"`
+	AccountID      uint     `gorm:"not null;index"`
+	Account        *Account `gorm:"foreignKey:AccountID"`
+	Debit          Money    `gorm:"not null;default:0"`
+	Credit         Money    `gorm:"not null;default:0"`
+}
+
+// defaultAccounts is the chart of accounts the hotel starts with
+var defaultAccounts = []Account{
+	{Code: accountCash, Name: "Cash on hand", Type: "ASSET", System: true},
+	{Code: accountKPay, Name: "KBZPay wallet", Type: "ASSET", System: true},
+	{Code: accountAYAPay, Name: "AYA Pay wallet", Type: "ASSET", System: true},
+	{Code: accountWavePay, Name: "Wave Pay wallet", Type: "ASSET", System: true},
+	{Code: accountBank, Name: "Bank", Type: "ASSET", System: true},
+	{Code: accountGuestLedger, Name: "Guest ledger", Type: "ASSET", System: true},
+	{Code: accountGuestDeposits, Name: "Guest deposits", Type: "LIABILITY", System: true},
+	{Code: accountTaxPayable, Name: "Commercial tax payable", Type: "LIABILITY", System: true},
+	{Code: accountOwnersEquity, Name: "Owner's equity", Type: "EQUITY", System: true},
+	{Code: accountRoomRevenue, Name: "Room revenue", Type: "REVENUE", System: true},
+	{Code: accountFoodRevenue, Name: "Food and beverage revenue", Type: "REVENUE", System: true},
+	{Code: accountOtherRevenue, Name: "Other revenue", Type: "REVENUE", System: true},
+	{Code: accountServiceCharge, Name: "Service charge", Type: "REVENUE", System: true},
+	{Code: accountDiscounts, Name: "Discounts and allowances", Type: "REVENUE", System: true},
+	{Code: accountUtilities, Name: "Utilities", Type: "EXPENSE", System: true},
+	{Code: accountSalaries, Name: "Salaries", Type: "EXPENSE", System: true},
+	{Code: accountSupplies, Name: "Supplies", Type: "EXPENSE", System: true},
+	{Code: accountMaintenance, Name: "Maintenance", Type: "EXPENSE", System: true},
+	{Code: accountFoodStock, Name: "Food stock", Type: "EXPENSE", System: true},
+	{Code: accountOtherExpenses, Name: "Other expenses", Type: "EXPENSE", System: true},
+}
+
+// seedChartOfAccounts creates any of the default accounts that don't exist yet
+func seedChartOfAccounts(tx *gorm.DB) error {
+	accounts := make([]Account, len(defaultAccounts))
+	copy(accounts, defaultAccounts)
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts).Error
+}
+
+// cashAccountFor is the account money taken by a payment method lands in
+func cashAccountFor(method string) string {
+	switch method {
+	case "KPAY":
+		return accountKPay
+	case "AYAPAY":
+		return accountAYAPay
+	case "WAVEPAY":
+		return accountWavePay
+	case "BANK":
+		return accountBank
+	}
+	return accountCash
+}
+
+// revenueAccountFor is the revenue account for an income type, following the room, food and other split
+// of the revenue reports
+func revenueAccountFor(incomeType string) string {
+	switch incomeType {
+	case "room":
+		return accountRoomRevenue
+	case "food":
+		return accountFoodRevenue
+	}
+	return accountOtherRevenue
+}
+
+// expenseAccountFor is the expense account for an expense category
+func expenseAccountFor(category string) string {
+	switch category {
+	case "UTILITIES":
+		return accountUtilities
+	case "SALARIES":
+		return accountSalaries
+	case "SUPPLIES":
+		return accountSupplies
+	case "MAINTENANCE":
+		return accountMaintenance
+	case "FOOD_STOCK":
+		return accountFoodStock
+	}
+	return accountOtherExpenses
+}
+
+// ledgerLine is one side of a posting, by account code. A negative amount posts to the other side, so a
+// reduction in a charge can be posted with the same lines as the charge.
+type ledgerLine struct {
+	Code   string
+	Debit  Money
+	Credit Money
+}
+
+func debit(code string, amount Money) ledgerLine  { return ledgerLine{Code: code, Debit: amount} }
+func credit(code string, amount Money) ledgerLine { return ledgerLine{Code: code, Credit: amount} }
+
+// postJournal saves a balanced entry. Zero lines are dropped and nothing is saved if none are left; the
+// entry is dated to the current business date unless it already has a date.
+func postJournal(tx *gorm.DB, entry *JournalEntry, lines []ledgerLine) error {
+	codes := make([]string, 0, len(lines))
+	for _, line := range lines {
+		codes = append(codes, line.Code)
+	}
+	var accounts []Account
+	if err := tx.Where("code IN ?", codes).Find(&accounts).Error; err != nil {
+		return err
+	}
+	accountIDs := make(map[string]uint, len(accounts))
+	for _, account := range accounts {
+		accountIDs[account.Code] = account.ID
+	}
+
+	entry.Lines = nil
+	var debits, credits Money
+	for _, line := range lines {
+		accountID, ok := accountIDs[line.Code]
+		if !ok {
+			return fmt.Errorf("account %s is missing from the chart of accounts", line.Code)
+		}
+		net := line.Debit.Sub(line.Credit)
+		if net.IsZero() {
+			continue
+		}
+		journalLine := JournalLine{AccountID: accountID}
+		if net.Sign() > 0 {
+			journalLine.Debit = net
+		} else {
+			journalLine.Credit = net.Neg()
+		}
+		debits = debits.Add(journalLine.Debit)
+		credits = credits.Add(journalLine.Credit)
+		entry.Lines = append(entry.Lines, journalLine)
+	}
+	if len(entry.Lines) == 0 {
+		return nil
+	}
+	if debits.Minor != credits.Minor {
+		return ErrUnbalancedEntry
+	}
+
+	if entry.Date.IsZero() {
+		businessDate, err := currentBusinessDate(tx)
+		if err != nil {
+			return err
+		}
+		entry.Date = businessDate
+	}
+	return tx.Create(entry).Error
+}
+
+// sourceBalances nets every entry posted for a source, as debit minus credit per account code
+func sourceBalances(tx *gorm.DB, sourceType string, sourceID uint) (map[string]Money, error) {
+	var rows []struct {
+		Code   string
+		Amount Money
+	}
+	if err := tx.Table("journal_lines").
+		Select("accounts.code, COALESCE(SUM(journal_lines.debit - journal_lines.credit), 0) as amount").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+		Joins("JOIN accounts ON accounts.id = journal_lines.account_id").
+		Where("journal_entries.source_type = ? AND journal_entries.source_id = ?", sourceType, sourceID).
+		Group("accounts.code").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]Money, len(rows))
+	for _, row := range rows {
+		balances[row.Code] = row.Amount
+	}
+	return balances, nil
+}
+
+// reverseSource posts an entry taking the part of what has been posted for a source that part is of
+// whole back off the ledger; a part equal to the whole reverses it completely
+func reverseSource(tx *gorm.DB, sourceType string, sourceID uint, part, whole Money, entry *JournalEntry) error {
+	balances, err := sourceBalances(tx, sourceType, sourceID)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]string, 0, len(balances))
+	for code := range balances {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	lines := make([]ledgerLine, 0, len(codes))
+	for _, code := range codes {
+		lines = append(lines, credit(code, balances[code].Share(part, whole)))
+	}
+	// Rounding each line of a partial reversal can leave it a pya out; the largest line takes the difference
+	if part.Minor != whole.Minor && len(lines) > 0 {
+		var net Money
+		largest := 0
+		for i := range lines {
+			net = net.Add(lines[i].Credit)
+			if lines[i].Credit.Abs().Minor > lines[largest].Credit.Abs().Minor {
+				largest = i
+			}
+		}
+		lines[largest].Credit = lines[largest].Credit.Sub(net)
+	}
+	return postJournal(tx, entry, lines)
+}
+
+// postIncomeJournal books money taken: the cash or wallet account it landed in against the account it
+// settles, which is the guest ledger for a stay, guest deposits for a reservation and revenue otherwise
+func postIncomeJournal(tx *gorm.DB, income Income, counterCode string) error {
+	description := fmt.Sprintf("Income #%d: %s %s (%s)", income.ID, income.Type, income.RevenueType, income.PaymentMethod)
+	if income.RoomNumber != 0 {
+		description += fmt.Sprintf(", room %d", income.RoomNumber)
+	}
+	entry := JournalEntry{
+		Date:        dateOnly(income.businessDate()),
+		Description: description,
+		SourceType:  "INCOME",
+		SourceID:    &income.ID,
+	}
+	return postJournal(tx, &entry, []ledgerLine{
+		debit(cashAccountFor(income.PaymentMethod), income.Amount),
+		credit(counterCode, income.Amount),
+	})
+}
+
+// incomeCounterAccount is the account an income row settles when it isn't tied to a reservation. Money
+// taken for a stay settles its folio, whose charges are already booked as revenue; anything else is
+// revenue when it is taken.
+func incomeCounterAccount(income Income) string {
+	if income.GuestID != nil {
+		return accountGuestLedger
+	}
+	return revenueAccountFor(income.Type)
+}
+
+// postIncomeReversal books a refund or void by reversing the matching share of the original income's
+// postings. An income taken before the ledger was kept has no postings to reverse, so the money is taken
+// back out of the cash or wallet account it landed in against the revenue it was booked to.
+func postIncomeReversal(tx *gorm.DB, original Income, counter Income) error {
+	if original.Amount.IsZero() {
+		return nil
+	}
+	entry := JournalEntry{
+		Date:        dateOnly(counter.businessDate()),
+		Description: fmt.Sprintf("Income #%d: %s of income #%d", counter.ID, counter.RevenueType, original.ID),
+		SourceType:  "INCOME",
+		SourceID:    &counter.ID,
+	}
+	balances, err := sourceBalances(tx, "INCOME", original.ID)
+	if err != nil {
+		return err
+	}
+	if len(balances) == 0 {
+		return postJournal(tx, &entry, []ledgerLine{
+			debit(revenueAccountFor(original.Type), counter.Amount.Neg()),
+			credit(cashAccountFor(original.PaymentMethod), counter.Amount.Neg()),
+		})
+	}
+	return reverseSource(tx, "INCOME", original.ID, counter.Amount.Neg(), original.Amount, &entry)
+}
+
+// postFolioCharge books a charge to a guest's folio against the revenue it earns, with any service charge
+// and commercial tax on it; a negative charge takes it back off
+func postFolioCharge(tx *gorm.DB, sourceType string, sourceID uint, revenueCode string, charge taxSplit, description string) error {
+	entry := JournalEntry{
+		Description: description,
+		SourceType:  sourceType,
+		SourceID:    &sourceID,
+	}
+	return postJournal(tx, &entry, []ledgerLine{
+		debit(accountGuestLedger, charge.Gross()),
+		credit(revenueCode, charge.Net),
+		credit(accountServiceCharge, charge.ServiceCharge),
+		credit(accountTaxPayable, charge.CommercialTax),
+	})
+}
+
+// postSaleJournal books a sale paid for on the spot, such as a walk-in order, with any service charge and
+// commercial tax on it. It is posted against the income row so a refund or void reverses it in proportion.
+func postSaleJournal(tx *gorm.DB, income Income, revenueCode string, sale taxSplit) error {
+	entry := JournalEntry{
+		Date:        dateOnly(income.businessDate()),
+		Description: fmt.Sprintf("Income #%d: %s sale (%s)", income.ID, income.Type, income.PaymentMethod),
+		SourceType:  "INCOME",
+		SourceID:    &income.ID,
+	}
+	return postJournal(tx, &entry, []ledgerLine{
+		debit(cashAccountFor(income.PaymentMethod), sale.Gross()),
+		credit(revenueCode, sale.Net),
+		credit(accountServiceCharge, sale.ServiceCharge),
+		credit(accountTaxPayable, sale.CommercialTax),
+	})
+}
+
+// postLoyaltyDiscount books a loyalty discount taken off a guest's folio; a negative amount puts a
+// withdrawn discount back on the folio
+func postLoyaltyDiscount(tx *gorm.DB, guest Guests, amount Money) error {
+	guestID := uint(guest.ID)
+	entry := JournalEntry{
+		Description: fmt.Sprintf("Room %d loyalty discount", guest.RoomNumber),
+		SourceType:  "LOYALTY_DISCOUNT",
+		SourceID:    &guestID,
+	}
+	return postJournal(tx, &entry, []ledgerLine{
+		debit(accountDiscounts, amount),
+		credit(accountGuestLedger, amount),
+	})
+}
+
+// postExpenseJournal books an expense against the account it was paid from
+func postExpenseJournal(tx *gorm.DB, expense Expense) error {
+	description := fmt.Sprintf("Expense #%d: %s", expense.ID, expense.Category)
+	if expense.Vendor != nil && *expense.Vendor != "" {
+		description += " - " + *expense.Vendor
+	}
+	entry := JournalEntry{
+		Date:        dateOnly(expense.ExpenseDate),
+		Description: description,
+		SourceType:  "EXPENSE",
+		SourceID:    &expense.ID,
+	}
+	return postJournal(tx, &entry, []ledgerLine{
+		debit(expenseAccountFor(expense.Category), expense.Amount),
+		credit(cashAccountFor(expense.PaymentMethod), expense.Amount),
+	})
+}
+
+// reverseExpenseJournal takes an expense's postings back off, dated to when it was booked
+func reverseExpenseJournal(tx *gorm.DB, expense Expense) error {
+	entry := JournalEntry{
+		Date:        dateOnly(expense.ExpenseDate),
+		Description: fmt.Sprintf("Reversal of expense #%d", expense.ID),
+		SourceType:  "EXPENSE",
+		SourceID:    &expense.ID,
+	}
+	return reverseSource(tx, "EXPENSE", expense.ID, expense.Amount, expense.Amount, &entry)
+}
+
+// accountBalance is an account's debit and credit totals over a period
+type accountBalance struct {
+	ID      uint   `json:"id"`
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Debit   Money  `json:"debit"`
+	Credit  Money  `json:"credit"`
+	Balance Money  `json:"balance"` // On the account's normal side: debit for assets and expenses, credit otherwise
+}
+
+// accountBalances totals the postings to every account between start and end, inclusive
+func accountBalances(db *gorm.DB, start, end string) ([]accountBalance, error) {
+	var balances []accountBalance
+	if err := db.Table("accounts").
+		Select(`accounts.id, accounts.code, accounts.name, accounts.type,
+			COALESCE(SUM(journal_lines.debit), 0) as debit,
+			COALESCE(SUM(journal_lines.credit), 0) as credit`).
+		Joins(`LEFT JOIN journal_lines ON journal_lines.account_id = accounts.id
+			AND journal_lines.journal_entry_id IN (SELECT id FROM journal_entries WHERE date BETWEEN ? AND ?)`, start, end).
+		Group("accounts.id, accounts.code, accounts.name, accounts.type").
+		Order("accounts.code").
+		Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range balances {
+		if balances[i].Type == "ASSET" || balances[i].Type == "EXPENSE" {
+			balances[i].Balance = balances[i].Debit.Sub(balances[i].Credit)
+		} else {
+			balances[i].Balance = balances[i].Credit.Sub(balances[i].Debit)
+		}
+	}
+	return balances, nil
+}
+
+// parseLedgerPeriod reads the :start and :end date parameters
+func parseLedgerPeriod(c *gin.Context) (string, string, bool) {
+	start, end := c.Param("start"), c.Param("end")
+	if _, err := time.Parse("2006-01-02", start); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start date format. Use YYYY-MM-DD"})
+		return "", "", false
+	}
+	if _, err := time.Parse("2006-01-02", end); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end date format. Use YYYY-MM-DD"})
+		return "", "", false
+	}
+	return start, end, true
+}
+
+func GetAccounts(c *gin.Context) {
+	var accounts []Account
+	if err := DB.Order("code").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+func CreateAccount(c *gin.Context) {
+	var account Account
+	if err := c.BindJSON(&account); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	account.Code = strings.TrimSpace(account.Code)
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Code == "" || account.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Code and name are required"})
+		return
+	}
+
+	account.ID = 0
+	account.System = false
+	if err := DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create account: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+// UpdateAccount renames or deactivates an account. The code and type are fixed once entries may have
+// been posted to it.
+func UpdateAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	var account Account
+	if err := DB.First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Account not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var request struct {
+		Name   *string
+		Active *bool
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if request.Name != nil && strings.TrimSpace(*request.Name) != "" {
+		account.Name = strings.TrimSpace(*request.Name)
+	}
+	if request.Active != nil {
+		if account.System && !*request.Active {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "System accounts can't be deactivated"})
+			return
+		}
+		account.Active = request.Active
+	}
+
+	if err := DB.Save(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+// GetJournalEntries lists the journal between the start and end query dates, optionally for one source type
+func GetJournalEntries(c *gin.Context) {
+	query := DB.Preload("Lines.Account").Order("date, id")
+	if start := c.Query("start"); start != "" {
+		query = query.Where("date >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("date <= ?", end)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source_type = ?", source)
+	}
+
+	var entries []JournalEntry
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch journal entries"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// CreateJournalEntry posts a manual entry, such as opening balances or the accountant's month-end adjustments
+func CreateJournalEntry(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	var request struct {
+		Date        string
+		Description string
+		Lines       []ledgerLine
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	date, err := time.Parse("2006-01-02", request.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+	if strings.TrimSpace(request.Description) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A description is required"})
+		return
+	}
+	if len(request.Lines) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "An entry needs at least two lines"})
+		return
+	}
+	for _, line := range request.Lines {
+		if line.Debit.Sign() < 0 || line.Credit.Sign() < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Debits and credits can't be negative"})
+			return
+		}
+	}
+
+	entry := JournalEntry{
+		Date:        date,
+		Description: strings.TrimSpace(request.Description),
+		SourceType:  "MANUAL",
+		PostedBy:    &adminID,
+	}
+	tx := DB.Begin()
+	if err := postJournal(tx, &entry, request.Lines); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if entry.ID == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "An entry needs at least one non-zero line"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetAccountLedger lists the postings to one account between two dates, with its running balance
+func GetAccountLedger(c *gin.Context) {
+	id := c.Param("id")
+	start, end, ok := parseLedgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	var account Account
+	if err := DB.First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Account not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	normal := func(amount Money) Money { return amount }
+	if account.Type != "ASSET" && account.Type != "EXPENSE" {
+		normal = Money.Neg
+	}
+
+	var opening Money
+	if err := DB.Table("journal_lines").
+		Select("COALESCE(SUM(journal_lines.debit - journal_lines.credit), 0)").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+		Where("journal_lines.account_id = ? AND journal_entries.date < ?", account.ID, start).
+		Scan(&opening).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch opening balance"})
+		return
+	}
+
+	var lines []struct {
+		EntryID     uint      `json:"entryId"`
+		Date        time.Time `json:"date"`
+		Description string    `json:"description"`
+		SourceType  string    `json:"sourceType"`
+		Debit       Money     `json:"debit"`
+		Credit      Money     `json:"credit"`
+		Balance     Money     `json:"balance" gorm:"-"`
+	}
+	if err := DB.Table("journal_lines").
+		Select("journal_entries.id as entry_id, journal_entries.date, journal_entries.description, journal_entries.source_type, journal_lines.debit, journal_lines.credit").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+		Where("journal_lines.account_id = ? AND journal_entries.date BETWEEN ? AND ?", account.ID, start, end).
+		Order("journal_entries.date, journal_entries.id").
+		Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch account postings"})
+		return
+	}
+
+	balance := normal(opening)
+	openingBalance := balance
+	for i := range lines {
+		balance = balance.Add(normal(lines[i].Debit.Sub(lines[i].Credit)))
+		lines[i].Balance = balance
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account":        account,
+		"openingBalance": openingBalance,
+		"closingBalance": balance,
+		"lines":          lines,
+	})
+}
+
+// GetTrialBalance lists every account's balance as of a date; total debits and credits must match
+func GetTrialBalance(c *gin.Context) {
+	date := c.Param("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	balances, err := accountBalances(DB, "0001-01-01", date)
+	if err != nil {
+		fmt.Printf("Error building trial balance: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build trial balance"})
+		return
+	}
+
+	type trialLine struct {
+		Code   string `json:"code"`
+		Name   string `json:"name"`
+		Type   string `json:"type"`
+		Debit  Money  `json:"debit"`
+		Credit Money  `json:"credit"`
+	}
+	lines := []trialLine{}
+	var totalDebit, totalCredit Money
+	for _, balance := range balances {
+		net := balance.Debit.Sub(balance.Credit)
+		if net.IsZero() {
+			continue
+		}
+		line := trialLine{Code: balance.Code, Name: balance.Name, Type: balance.Type}
+		if net.Sign() > 0 {
+			line.Debit = net
+		} else {
+			line.Credit = net.Neg()
+		}
+		totalDebit = totalDebit.Add(line.Debit)
+		totalCredit = totalCredit.Add(line.Credit)
+		lines = append(lines, line)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":        date,
+		"accounts":    lines,
+		"totalDebit":  totalDebit,
+		"totalCredit": totalCredit,
+		"balanced":    totalDebit.Minor == totalCredit.Minor,
+	})
+}
+
+// GetProfitAndLoss reports revenue and expenses by account between two dates
+func GetProfitAndLoss(c *gin.Context) {
+	start, end, ok := parseLedgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	balances, err := accountBalances(DB, start, end)
+	if err != nil {
+		fmt.Printf("Error building profit and loss: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build profit and loss"})
+		return
+	}
+
+	revenue := []accountBalance{}
+	expenses := []accountBalance{}
+	var totalRevenue, totalExpenses Money
+	for _, balance := range balances {
+		switch balance.Type {
+		case "REVENUE":
+			revenue = append(revenue, balance)
+			totalRevenue = totalRevenue.Add(balance.Balance)
+		case "EXPENSE":
+			expenses = append(expenses, balance)
+			totalExpenses = totalExpenses.Add(balance.Balance)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start":         start,
+		"end":           end,
+		"revenue":       revenue,
+		"expenses":      expenses,
+		"totalRevenue":  totalRevenue,
+		"totalExpenses": totalExpenses,
+		"netProfit":     totalRevenue.Sub(totalExpenses),
+	})
+}
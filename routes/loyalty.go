@@ -0,0 +1,407 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"net/http"
+	"time"
+)
+
+type LoyaltyTransaction struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	ProfileID   int       `gorm:"not null;index"`
+	GuestID     *int      `gorm:"null;index"`
+	IncomeID    *uint     `gorm:"null;index"`
+	Type        string    `gorm:"type:enum('EARN','REDEEM','REVERSAL');not null"`
+	Points      int       `gorm:"not null"` // Positive when credited, negative when debited
+	Description string    `gorm:"type:varchar(255)"`
+	ReversedID  *uint     `gorm:"null;index"` // Transaction this one reverses
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+type loyaltyTier struct {
+	Name       string
+	MinPoints  int     // Lifetime points needed to reach the tier
+	Multiplier float64 // Applied to points earned while in the tier
+}
+
+// Tiers are ordered from highest to lowest
+var loyaltyTiers = []loyaltyTier{
+	{Name: "GOLD", MinPoints: 5000, Multiplier: 1.5},
+	{Name: "SILVER", MinPoints: 1500, Multiplier: 1.25},
+	{Name: "BASIC", MinPoints: 0, Multiplier: 1},
+}
+
+const (
+	loyaltySpendPerPoint = 1000 // MMK of paid room or food spend per point
+	loyaltyPointValue    = 10   // MMK discount per redeemed point
+)
+
+func tierForPoints(lifetimePoints int) loyaltyTier {
+	for _, tier := range loyaltyTiers {
+		if lifetimePoints >= tier.MinPoints {
+			return tier
+		}
+	}
+	return loyaltyTiers[len(loyaltyTiers)-1]
+}
+
+// lockLoyaltyProfile reads a profile and holds it until the transaction ends, so two point changes running
+// at once can't both work from the same balance
+func lockLoyaltyProfile(tx *gorm.DB, profile *GuestProfile, id interface{}) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(profile, id).Error
+}
+
+// adjustLoyaltyBalance applies a points change to the profile and keeps its lifetime total and tier in
+// step. The profile must have been read with lockLoyaltyProfile.
+func adjustLoyaltyBalance(tx *gorm.DB, profile *GuestProfile, points int, countsTowardsTier bool) error {
+	profile.LoyaltyPoints += points
+	if countsTowardsTier {
+		profile.LifetimePoints += points
+		if profile.LifetimePoints < 0 {
+			profile.LifetimePoints = 0
+		}
+	}
+	profile.LoyaltyTier = tierForPoints(profile.LifetimePoints).Name
+
+	return tx.Model(profile).Updates(map[string]interface{}{
+		"loyalty_points":  profile.LoyaltyPoints,
+		"lifetime_points": profile.LifetimePoints,
+		"loyalty_tier":    profile.LoyaltyTier,
+	}).Error
+}
+
+// accrueLoyaltyPoints earns points for a paid room or food income linked to a stay with a guest profile
+func accrueLoyaltyPoints(tx *gorm.DB, income Income) error {
+	if income.GuestID == nil || income.Amount.Sign() <= 0 || (income.Type != "room" && income.Type != "food") {
+		return nil
+	}
+
+	var guest Guests
+	if err := tx.First(&guest, *income.GuestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if guest.ProfileID == nil {
+		return nil
+	}
+
+	var profile GuestProfile
+	if err := lockLoyaltyProfile(tx, &profile, *guest.ProfileID); err != nil {
+		return err
+	}
+
+	tier := tierForPoints(profile.LifetimePoints)
+	points := int(math.Floor(income.Amount.Ratio(kyat(loyaltySpendPerPoint)) * tier.Multiplier))
+	if points <= 0 {
+		return nil
+	}
+
+	transaction := LoyaltyTransaction{
+		ProfileID:   profile.ID,
+		GuestID:     &guest.ID,
+		IncomeID:    &income.ID,
+		Type:        "EARN",
+		Points:      points,
+		Description: fmt.Sprintf("Earned on %s payment for room %d", income.Type, income.RoomNumber),
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+	return adjustLoyaltyBalance(tx, &profile, points, true)
+}
+
+// pointsReversed totals the points already taken back from a transaction, which a partial refund can
+// have done in part
+func pointsReversed(tx *gorm.DB, original LoyaltyTransaction) (int, error) {
+	var points int
+	err := tx.Model(&LoyaltyTransaction{}).
+		Where("reversed_id = ?", original.ID).
+		Select("COALESCE(SUM(points), 0)").
+		Scan(&points).Error
+	return -points, err
+}
+
+// reverseLoyaltyPoints takes points back from a transaction, or gives them back for a redemption
+func reverseLoyaltyPoints(tx *gorm.DB, original LoyaltyTransaction, points int, description string) error {
+	var profile GuestProfile
+	if err := lockLoyaltyProfile(tx, &profile, original.ProfileID); err != nil {
+		return err
+	}
+
+	reversal := LoyaltyTransaction{
+		ProfileID:   original.ProfileID,
+		GuestID:     original.GuestID,
+		IncomeID:    original.IncomeID,
+		Type:        "REVERSAL",
+		Points:      -points,
+		Description: description,
+		ReversedID:  &original.ID,
+	}
+	if err := tx.Create(&reversal).Error; err != nil {
+		return err
+	}
+	return adjustLoyaltyBalance(tx, &profile, reversal.Points, original.Type == "EARN")
+}
+
+// reverseLoyaltyTransactions reverses whatever is left of every matching transaction
+func reverseLoyaltyTransactions(tx *gorm.DB, query *gorm.DB, description string) (int, error) {
+	var transactions []LoyaltyTransaction
+	if err := query.Where("type IN ?", []string{"EARN", "REDEEM"}).Find(&transactions).Error; err != nil {
+		return 0, err
+	}
+
+	reversed := 0
+	for _, original := range transactions {
+		already, err := pointsReversed(tx, original)
+		if err != nil {
+			return reversed, err
+		}
+		if original.Points == already {
+			continue
+		}
+		if err := reverseLoyaltyPoints(tx, original, original.Points-already, description); err != nil {
+			return reversed, err
+		}
+		reversed++
+	}
+	return reversed, nil
+}
+
+// reverseLoyaltyShare takes back the points earned on an income in proportion to how much of it has been
+// refunded in all, so a guest keeps the points on what they kept
+func reverseLoyaltyShare(tx *gorm.DB, income Income, refunded Money) error {
+	if income.Amount.Sign() <= 0 {
+		return nil
+	}
+	var earned []LoyaltyTransaction
+	if err := tx.Where("income_id = ? AND type = ?", income.ID, "EARN").Find(&earned).Error; err != nil {
+		return err
+	}
+
+	for _, original := range earned {
+		already, err := pointsReversed(tx, original)
+		if err != nil {
+			return err
+		}
+		points := int(roundHalfAway(float64(original.Points)*refunded.Ratio(income.Amount))) - already
+		if points <= 0 {
+			continue
+		}
+		if err := reverseLoyaltyPoints(tx, original, points, fmt.Sprintf("Reversed for partly refunded income #%d", income.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseLoyaltyForIncome takes back the points earned on a refunded income
+func reverseLoyaltyForIncome(tx *gorm.DB, incomeID uint) (int, error) {
+	query := tx.Model(&LoyaltyTransaction{}).Where("income_id = ?", incomeID)
+	return reverseLoyaltyTransactions(tx, query, fmt.Sprintf("Reversed for refunded income #%d", incomeID))
+}
+
+// reverseLoyaltyForStay takes back the points earned on a refunded stay and returns any points redeemed on it
+func reverseLoyaltyForStay(tx *gorm.DB, guestID int) (int, error) {
+	query := tx.Model(&LoyaltyTransaction{}).Where("guest_id = ?", guestID)
+	return reverseLoyaltyTransactions(tx, query, fmt.Sprintf("Reversed for refunded stay #%d", guestID))
+}
+
+func GetLoyaltyAccount(c *gin.Context) {
+	profileID := c.Param("profileId")
+
+	var profile GuestProfile
+	if err := DB.First(&profile, profileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest profile not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	tier := tierForPoints(profile.LifetimePoints)
+	account := gin.H{
+		"profileId":      profile.ID,
+		"name":           profile.Name,
+		"points":         profile.LoyaltyPoints,
+		"lifetimePoints": profile.LifetimePoints,
+		"tier":           tier.Name,
+		"multiplier":     tier.Multiplier,
+		"redeemValue":    kyat(int64(profile.LoyaltyPoints * loyaltyPointValue)),
+	}
+
+	// Tiers are ordered from highest, so the one before the current tier is the next to reach
+	for i, t := range loyaltyTiers {
+		if t.Name == tier.Name && i > 0 {
+			account["nextTier"] = loyaltyTiers[i-1].Name
+			account["pointsToNextTier"] = loyaltyTiers[i-1].MinPoints - profile.LifetimePoints
+		}
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+func GetLoyaltyTransactions(c *gin.Context) {
+	profileID := c.Param("profileId")
+
+	var transactions []LoyaltyTransaction
+	if err := DB.Where("profile_id = ?", profileID).
+		Order("created_at DESC").
+		Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch loyalty transactions"})
+		return
+	}
+	c.JSON(http.StatusOK, transactions)
+}
+
+// RedeemLoyaltyPoints converts points into a discount on an active stay's bill
+func RedeemLoyaltyPoints(c *gin.Context) {
+	profileID := c.Param("profileId")
+
+	var request struct {
+		GuestID int
+		Points  int
+	}
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	if request.Points <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Points must be greater than zero"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var profile GuestProfile
+	if err := lockLoyaltyProfile(tx, &profile, profileID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest profile not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var guest Guests
+	if err := tx.Where("id = ? AND profile_id = ? AND status = ?", request.GuestID, profile.ID, "ACTIVE").First(&guest).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "No active stay found for this guest profile"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+
+	if request.Points > profile.LoyaltyPoints {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Only %d points available", profile.LoyaltyPoints)})
+		return
+	}
+
+	discount := kyat(int64(request.Points * loyaltyPointValue))
+	transaction := LoyaltyTransaction{
+		ProfileID:   profile.ID,
+		GuestID:     &guest.ID,
+		Type:        "REDEEM",
+		Points:      -request.Points,
+		Description: fmt.Sprintf("Redeemed for %s discount on room %d", discount, guest.RoomNumber),
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to redeem points"})
+		return
+	}
+
+	if err := adjustLoyaltyBalance(tx, &profile, transaction.Points, false); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update loyalty balance"})
+		return
+	}
+
+	if err := tx.Model(&guest).Update("loyalty_discount", gorm.Expr("loyalty_discount + ?", discount)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to apply discount"})
+		return
+	}
+	if err := postLoyaltyDiscount(tx, guest, discount); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post discount to the ledger"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Points redeemed successfully",
+		"discount": discount,
+		"points":   profile.LoyaltyPoints,
+	})
+}
+
+func ReverseIncomeLoyalty(c *gin.Context) {
+	var incomeID uint
+	if _, err := fmt.Sscan(c.Param("id"), &incomeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid income ID"})
+		return
+	}
+
+	tx := DB.Begin()
+	reversed, err := reverseLoyaltyForIncome(tx, incomeID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reverse loyalty points"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Loyalty points reversed", "reversed": reversed})
+}
+
+func ReverseStayLoyalty(c *gin.Context) {
+	var guestID int
+	if _, err := fmt.Sscan(c.Param("id"), &guestID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid guest ID"})
+		return
+	}
+
+	tx := DB.Begin()
+	reversed, err := reverseLoyaltyForStay(tx, guestID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reverse loyalty points"})
+		return
+	}
+
+	// Any discount taken with the returned points no longer applies
+	var guest Guests
+	if err := tx.First(&guest, guestID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+	discount := guest.LoyaltyDiscount
+	if err := tx.Model(&guest).Update("loyalty_discount", 0).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to clear loyalty discount"})
+		return
+	}
+	if err := postLoyaltyDiscount(tx, guest, discount.Neg()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post discount to the ledger"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Loyalty points reversed", "reversed": reversed})
+}
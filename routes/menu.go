@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MenuCategory struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	Name      string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	SortOrder int    `gorm:"not null;default:0"`
+}
+
+// hotelLocation is Myanmar time, which has no daylight saving, for menu availability hours
+var hotelLocation = time.FixedZone("MMT", 6*60*60+30*60)
+
+const menuPhotoSubdir = "menu-photos"
+
+// parseClock reads an "HH:MM" time of day as minutes since midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// availableAt reports whether the item can be ordered at the given time. Hours that wrap past
+// midnight, such as 22:00 to 02:00, are supported; an item without hours is available all day.
+func (menu Menu) availableAt(t time.Time) bool {
+	if menu.Active != nil && !*menu.Active {
+		return false
+	}
+	if menu.SoldOut {
+		return false
+	}
+	if menu.AvailableFrom == nil || menu.AvailableTo == nil {
+		return true
+	}
+
+	from, err := parseClock(*menu.AvailableFrom)
+	if err != nil {
+		return true
+	}
+	to, err := parseClock(*menu.AvailableTo)
+	if err != nil {
+		return true
+	}
+
+	local := t.In(hotelLocation)
+	now := local.Hour()*60 + local.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// validateMenu checks the fields a client can set on a menu item
+func validateMenu(menu *Menu) error {
+	menu.FoodName = strings.TrimSpace(menu.FoodName)
+	if menu.FoodName == "" {
+		return errors.New("food name is required")
+	}
+	if menu.Price.Sign() < 0 {
+		return errors.New("price can't be negative")
+	}
+	if (menu.AvailableFrom == nil) != (menu.AvailableTo == nil) {
+		return errors.New("both available from and available to are needed for availability hours")
+	}
+	if menu.AvailableFrom != nil {
+		if _, err := parseClock(*menu.AvailableFrom); err != nil {
+			return err
+		}
+		if _, err := parseClock(*menu.AvailableTo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// menuCategoryScope filters menu items by category ID or name when the category query parameter is set
+func menuCategoryScope(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		category := c.Query("category")
+		if category == "" {
+			return db
+		}
+		if id, err := strconv.Atoi(category); err == nil {
+			return db.Where("category_id = ?", id)
+		}
+		return db.Where("category_id IN (?)", DB.Model(&MenuCategory{}).Select("id").Where("name = ?", category))
+	}
+}
+
+// filterAvailable drops items that can't be ordered right now when available=true is requested
+func filterAvailable(c *gin.Context, menus []Menu) []Menu {
+	if c.Query("available") != "true" {
+		return menus
+	}
+	now := time.Now()
+	available := []Menu{}
+	for _, menu := range menus {
+		if menu.availableAt(now) {
+			available = append(available, menu)
+		}
+	}
+	return available
+}
+
+func GetMenuCategories(c *gin.Context) {
+	var categories []MenuCategory
+	if err := DB.Order("sort_order, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch menu categories"})
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func CreateMenuCategory(c *gin.Context) {
+	var category MenuCategory
+	if err := c.BindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Category name is required"})
+		return
+	}
+
+	if err := DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create category: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func UpdateMenuCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	var category MenuCategory
+	if err := DB.First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Category not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	categoryID := category.ID
+	if err := c.BindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	category.ID = categoryID
+
+	if err := DB.Save(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func UploadMenuPhoto(c *gin.Context) {
+	id := c.Param("id")
+
+	var menu Menu
+	if err := DB.First(&menu, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Menu not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	path, err := saveUpload(c, "photo", menuPhotoSubdir, fmt.Sprintf("menu-%d", menu.ID), ".jpg", ".jpeg", ".png", ".webp")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	previous := menu.PhotoPath
+	if err := DB.Model(&menu).Update("photo_path", path).Error; err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save photo"})
+		return
+	}
+	if previous != nil {
+		os.Remove(*previous)
+	}
+
+	c.JSON(http.StatusOK, menu)
+}
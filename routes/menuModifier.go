@@ -0,0 +1,261 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+type ModifierGroup struct {
+	ID          uint             `gorm:"primaryKey;autoIncrement"`
+	MenuID      uint             `gorm:"not null;index"`
+	Name        string           `gorm:"not null"`
+	Required    bool             `gorm:"not null;default:false"` // At least one option must be chosen
+	MultiSelect bool             `gorm:"not null;default:false"` // More than one option may be chosen
+	SortOrder   int              `gorm:"not null;default:0"`
+	Options     []ModifierOption `gorm:"foreignKey:ModifierGroupID"`
+}
+
+type ModifierOption struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	ModifierGroupID uint   `gorm:"not null;index"`
+	Name            string `gorm:"not null"`
+	PriceDelta      Money  `gorm:"not null;default:0"` // Added to the item's unit price, may be negative
+	Active          *bool  `gorm:"not null;default:true"`
+}
+
+// FoodOrderItemModifier is the option chosen on an order line, copied so menu edits don't change past orders
+type FoodOrderItemModifier struct {
+	ID               uint   `gorm:"primaryKey;autoIncrement"`
+	FoodOrderItemID  uint   `gorm:"not null;index"`
+	ModifierOptionID uint   `gorm:"not null"`
+	GroupName        string `gorm:"not null"`
+	OptionName       string `gorm:"not null"`
+	PriceDelta       Money  `gorm:"not null;default:0"`
+}
+
+// resolveModifiers checks the chosen options against the menu item's modifier groups and returns
+// the order line modifiers with the total price change they make to one unit
+func resolveModifiers(tx *gorm.DB, menu Menu, optionIDs []uint) ([]FoodOrderItemModifier, Money, error) {
+	var groups []ModifierGroup
+	if err := tx.Preload("Options").Where("menu_id = ?", menu.ID).Find(&groups).Error; err != nil {
+		return nil, Money{}, err
+	}
+
+	type choice struct {
+		group  ModifierGroup
+		option ModifierOption
+	}
+	available := make(map[uint]choice)
+	for _, group := range groups {
+		for _, option := range group.Options {
+			available[option.ID] = choice{group: group, option: option}
+		}
+	}
+
+	var modifiers []FoodOrderItemModifier
+	var delta Money
+	chosenPerGroup := make(map[uint]int)
+	seen := make(map[uint]bool)
+	for _, optionID := range optionIDs {
+		if seen[optionID] {
+			continue
+		}
+		seen[optionID] = true
+
+		chosen, ok := available[optionID]
+		if !ok {
+			return nil, Money{}, fmt.Errorf("option %d is not offered for %s", optionID, menu.FoodName)
+		}
+		if chosen.option.Active != nil && !*chosen.option.Active {
+			return nil, Money{}, fmt.Errorf("%s is not available for %s", chosen.option.Name, menu.FoodName)
+		}
+
+		chosenPerGroup[chosen.group.ID]++
+		modifiers = append(modifiers, FoodOrderItemModifier{
+			ModifierOptionID: chosen.option.ID,
+			GroupName:        chosen.group.Name,
+			OptionName:       chosen.option.Name,
+			PriceDelta:       chosen.option.PriceDelta,
+		})
+		delta = delta.Add(chosen.option.PriceDelta)
+	}
+
+	for _, group := range groups {
+		count := chosenPerGroup[group.ID]
+		if group.Required && count == 0 {
+			return nil, Money{}, fmt.Errorf("choose a %s for %s", strings.ToLower(group.Name), menu.FoodName)
+		}
+		if !group.MultiSelect && count > 1 {
+			return nil, Money{}, fmt.Errorf("only one %s can be chosen for %s", strings.ToLower(group.Name), menu.FoodName)
+		}
+	}
+	return modifiers, delta, nil
+}
+
+func GetMenuModifiers(c *gin.Context) {
+	menuID := c.Param("id")
+
+	var groups []ModifierGroup
+	if err := DB.Preload("Options").
+		Where("menu_id = ?", menuID).
+		Order("sort_order, id").
+		Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch modifiers"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// CreateModifierGroup adds a modifier group, with any options given, to a menu item
+func CreateModifierGroup(c *gin.Context) {
+	menuID := c.Param("id")
+
+	var menu Menu
+	if err := DB.First(&menu, menuID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Menu not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var group ModifierGroup
+	if err := c.BindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(group.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Modifier group name is required"})
+		return
+	}
+
+	group.ID = 0
+	group.MenuID = menu.ID
+	for i := range group.Options {
+		group.Options[i].ID = 0
+		if strings.TrimSpace(group.Options[i].Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Every option needs a name"})
+			return
+		}
+	}
+
+	if err := DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create modifier group: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func UpdateModifierGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var group ModifierGroup
+	if err := DB.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Modifier group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	groupID, menuID := group.ID, group.MenuID
+	if err := c.BindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	group.ID, group.MenuID = groupID, menuID
+
+	if err := DB.Omit("Options").Save(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func DeleteModifierGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var group ModifierGroup
+	if err := DB.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Modifier group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := DB.Select("Options").Delete(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete modifier group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Modifier group deleted successfully"})
+}
+
+func CreateModifierOption(c *gin.Context) {
+	groupID := c.Param("id")
+
+	var group ModifierGroup
+	if err := DB.First(&group, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Modifier group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	var option ModifierOption
+	if err := c.BindJSON(&option); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(option.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Option name is required"})
+		return
+	}
+
+	option.ID = 0
+	option.ModifierGroupID = group.ID
+	if err := DB.Create(&option).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create option: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, option)
+}
+
+// UpdateModifierOption edits an option; options are deactivated rather than deleted
+func UpdateModifierOption(c *gin.Context) {
+	id := c.Param("id")
+
+	var option ModifierOption
+	if err := DB.First(&option, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Option not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	optionID, groupID := option.ID, option.ModifierGroupID
+	if err := c.BindJSON(&option); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	option.ID, option.ModifierGroupID = optionID, groupID
+
+	if err := DB.Save(&option).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, option)
+}
@@ -0,0 +1,290 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaMigration records the data migrations that have already been applied
+type SchemaMigration struct {
+	Name      string    `gorm:"primaryKey;type:varchar(100)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+type dataMigration struct {
+	name string
+	run  func(tx *gorm.DB) error
+	// dropColumns are dropped once run has committed. MySQL commits DDL straight away, so they can't be
+	// part of the data transaction; each is dropped on its own and checked again on every start, so a drop
+	// that failed part way is finished the next time.
+	dropColumns []legacyColumn
+	// beforeSchema migrations rewrite columns that AutoMigrate is about to change the type of, so they
+	// run first when every migration before them has been applied
+	beforeSchema bool
+}
+
+// legacyColumn is a column a data migration has moved out of a table
+type legacyColumn struct {
+	model  interface{}
+	column string
+}
+
+// dataMigrations run once each, in order, after AutoMigrate has brought the tables up to date, except
+// that a beforeSchema migration runs before AutoMigrate when it is next in line
+var dataMigrations = []dataMigration{
+	{name: "0001_food_order_status", run: migrateFoodOrderStatus},
+	{name: "0002_food_order_items", run: migrateFoodOrderItems, dropColumns: []legacyColumn{
+		{&FoodOrder{}, "food_name"}, {&FoodOrder{}, "price"}, {&FoodOrder{}, "quantity"},
+	}},
+	{name: "0003_menu_catalogue", run: migrateMenuCatalogue, dropColumns: []legacyColumn{{&Menu{}, "food_price"}}},
+	{name: "0004_food_revenue_rollup", run: migrateFoodRevenueRollup},
+	{name: "0005_payments", run: migratePayments},
+	{name: "0006_chart_of_accounts", run: migrateChartOfAccounts},
+	{name: "0007_tax_accounts", run: migrateChartOfAccounts},
+	{name: "0008_currencies", run: migrateCurrencies},
+	{name: "0009_money_minor_units", run: migrateMoneyMinorUnits, beforeSchema: true},
+	{name: "0010_walk_in_income_orders", run: migrateWalkInIncomeOrders},
+}
+
+// RunPreSchemaMigrations applies the pending beforeSchema migrations that are next in line; main runs it
+// before AutoMigrate. A new database has nothing to rewrite, so they wait for RunDataMigrations.
+func RunPreSchemaMigrations() error {
+	if !DB.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	return runDataMigrations(true)
+}
+
+// RunDataMigrations applies every data migration that hasn't been applied yet, each in its own transaction,
+// and then drops the columns they moved data out of
+func RunDataMigrations() error {
+	return runDataMigrations(false)
+}
+
+func runDataMigrations(beforeSchema bool) error {
+	for _, migration := range dataMigrations {
+		var applied SchemaMigration
+		err := DB.First(&applied, "name = ?", migration.name).Error
+		if err == nil {
+			if !beforeSchema {
+				if err := dropLegacyColumns(migration); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if beforeSchema && !migration.beforeSchema {
+			// Anything after this needs the tables as AutoMigrate leaves them
+			return nil
+		}
+
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			if err := migration.run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Name: migration.name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("data migration %s: %w", migration.name, err)
+		}
+		fmt.Printf("Applied data migration %s\n", migration.name)
+		if !beforeSchema {
+			if err := dropLegacyColumns(migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropLegacyColumns drops whichever of a migration's old columns are still there
+func dropLegacyColumns(migration dataMigration) error {
+	for _, legacy := range migration.dropColumns {
+		if !DB.Migrator().HasColumn(legacy.model, legacy.column) {
+			continue
+		}
+		if err := DB.Migrator().DropColumn(legacy.model, legacy.column); err != nil {
+			return fmt.Errorf("data migration %s: dropping %s: %w", migration.name, legacy.column, err)
+		}
+	}
+	return nil
+}
+
+// Orders placed before the kitchen workflow existed were already served
+func migrateFoodOrderStatus(tx *gorm.DB) error {
+	return tx.Exec("UPDATE food_orders SET status = 'DELIVERED', delivered_at = order_time WHERE status = 'PLACED'").Error
+}
+
+// Orders used to hold a single FoodName, Price and Quantity; move each into an order line. The old columns
+// are dropped afterwards.
+func migrateFoodOrderItems(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn(&FoodOrder{}, "food_name") {
+		return nil
+	}
+
+	if err := tx.Exec(`INSERT INTO food_order_items (food_order_id, menu_id, food_name, unit_price, quantity, line_total)
+		SELECT fo.id, COALESCE((SELECT m.id FROM menus m WHERE m.food_name = fo.food_name LIMIT 1), 0),
+			fo.food_name, fo.price, fo.quantity, fo.price * fo.quantity
+		FROM food_orders fo`).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec("UPDATE food_orders SET total = price * quantity").Error
+}
+
+// parseLegacyPrice reads a menu price that was stored as text such as "3,500" or "3500 MMK"
+func parseLegacyPrice(value string) (float64, error) {
+	cleaned := strings.NewReplacer(",", "", "MMK", "", "Ks", "", " ", "").Replace(value)
+	return strconv.ParseFloat(cleaned, 64)
+}
+
+// Menu prices were free text; convert them to the numeric Price column and seed the starting categories.
+// The old column is dropped afterwards.
+func migrateMenuCatalogue(tx *gorm.DB) error {
+	for i, name := range []string{"Breakfast", "Mains", "Drinks"} {
+		category := MenuCategory{Name: name, SortOrder: i}
+		if err := tx.Where(MenuCategory{Name: name}).FirstOrCreate(&category).Error; err != nil {
+			return err
+		}
+	}
+
+	if !tx.Migrator().HasColumn(&Menu{}, "food_price") {
+		return nil
+	}
+
+	var legacy []struct {
+		ID        uint
+		FoodName  string
+		FoodPrice string
+	}
+	if err := tx.Table("menus").Select("id, food_name, food_price").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	for _, row := range legacy {
+		price, err := parseLegacyPrice(row.FoodPrice)
+		if err != nil {
+			// Leave unreadable prices at zero and mark the item inactive so nobody orders it for free
+			fmt.Printf("Menu item %d (%s) has an unreadable price %q, deactivating it\n", row.ID, row.FoodName, row.FoodPrice)
+			if err := tx.Table("menus").Where("id = ?", row.ID).Update("active", false).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Table("menus").Where("id = ?", row.ID).Update("price", price).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The food revenue rollup was never written before; fill it in for every order placed so far
+func migrateFoodRevenueRollup(tx *gorm.DB) error {
+	return rebuildFoodRevenue(tx, "0001-01-01", "9999-12-31")
+}
+
+// Stays and reservations kept a single AmountPaid; turn each into a payment so balances add up. Their
+// income rows were already recorded separately, so the payments aren't linked to one.
+func migratePayments(tx *gorm.DB) error {
+	if err := tx.Exec(`INSERT INTO payments (guest_id, amount, method, paid_at, created_at)
+		SELECT id, amount_paid, IF(payment_type = 'NONE', 'CASH', payment_type), checkin_date, NOW()
+		FROM guests WHERE amount_paid > 0`).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO payments (reservation_id, amount, method, paid_at, created_at)
+		SELECT id, amount_paid, IF(payment_type = 'NONE', 'CASH', payment_type), reservation_date, NOW()
+		FROM reservations WHERE amount_paid > 0`).Error
+}
+
+// The ledger starts empty; balances from before it existed are brought in with a manual opening entry
+func migrateChartOfAccounts(tx *gorm.DB) error {
+	return seedChartOfAccounts(tx)
+}
+
+// Everything taken before currencies were recorded was taken in kyat
+func migrateCurrencies(tx *gorm.DB) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&defaultCurrencies).Error; err != nil {
+		return err
+	}
+	for _, table := range []string{"incomes", "payments"} {
+		if err := tx.Exec("UPDATE "+table+" SET currency = ?, original_amount = amount, exchange_rate = 1 WHERE currency = ? AND original_amount = 0",
+			baseCurrency, baseCurrency).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moneyColumns are the columns holding amounts of money, by table
+var moneyColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"incomes", []string{"amount", "original_amount"}},
+	{"payments", []string{"amount", "original_amount"}},
+	{"guests", []string{"amount_paid", "room_charges", "extra_charges", "food_charges", "service_charges", "tax_charges", "loyalty_discount"}},
+	{"reservations", []string{"amount_paid"}},
+	{"group_reservations", []string{"amount_paid"}},
+	{"food_orders", []string{"total"}},
+	{"food_order_items", []string{"unit_price", "line_total"}},
+	{"food_order_item_modifiers", []string{"price_delta"}},
+	{"modifier_options", []string{"price_delta"}},
+	{"menus", []string{"price"}},
+	{"daily_food_revenues", []string{"revenue", "room_service", "walk_in"}},
+	{"room_prices", []string{"bnfp", "bcfp", "bsfp", "extra_bed", "hourly_rate", "family_room_fp"}},
+	{"amenities", []string{"price"}},
+	{"amenity_charges", []string{"unit_price", "amount"}},
+	{"cashier_shifts", []string{"opening_float", "expected_cash", "counted_cash", "variance"}},
+	{"shift_counts", []string{"expected", "counted", "variance"}},
+	{"expenses", []string{"amount"}},
+	{"daily_closes", []string{"room_charges_posted", "room_revenue", "food_revenue", "other_revenue", "total_revenue"}},
+	{"room_charges", []string{"amount"}},
+	{"refund_requests", []string{"amount"}},
+	{"refund_settings", []string{"approval_threshold"}},
+	{"tax_lines", []string{"net", "service_charge", "commercial_tax"}},
+	{"journal_lines", []string{"debit", "credit"}},
+}
+
+// Money was kept as a floating point number of major units; it is now a whole number of minor units.
+// Run before AutoMigrate turns the columns into BIGINT, this keeps any fractions of a kyat; run after it,
+// on a database that still had earlier migrations pending, it works the same on the rounded amounts.
+func migrateMoneyMinorUnits(tx *gorm.DB) error {
+	hasColumn := func(table, column string) bool {
+		return tx.Migrator().HasTable(table) && tx.Migrator().HasColumn(table, column)
+	}
+	for _, statement := range moneyMinorUnitUpdates(hasColumn) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moneyMinorUnitUpdates are the statements scaling each money column the database has, once each
+func moneyMinorUnitUpdates(hasColumn func(table, column string) bool) []string {
+	var statements []string
+	for _, money := range moneyColumns {
+		for _, column := range money.columns {
+			if hasColumn(money.table, column) {
+				statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = ROUND(%s * %d)", money.table, column, column, minorPerMajor))
+			}
+		}
+	}
+	return statements
+}
+
+// Walk-in order changes are now posted as income rows of their own, found by the order they belong to;
+// link the payments already taken to their orders.
+func migrateWalkInIncomeOrders(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE incomes JOIN food_orders ON food_orders.income_id = incomes.id
+		SET incomes.food_order_id = food_orders.id
+		WHERE incomes.food_order_id IS NULL`).Error
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+// TestMoneyMinorUnitUpdates checks the minor units migration scales every money column of every table
+// exactly once, and leaves out the columns a database doesn't have
+func TestMoneyMinorUnitUpdates(t *testing.T) {
+	models := []interface{}{
+		&Rooms{}, &Reservation{}, &Guests{}, &FoodOrder{}, &FoodOrderItem{}, &FoodOrderItemModifier{},
+		&MenuCategory{}, &ModifierGroup{}, &ModifierOption{}, &StockItem{}, &RecipeIngredient{}, &StockMovement{},
+		&Amenity{}, &AmenityCharge{}, &Payment{}, &PaymentProviderConfig{}, &CashierShift{}, &ShiftCount{},
+		&Expense{}, &TaxSettings{}, &TaxRule{}, &TaxLine{}, &Currency{}, &ExchangeRate{}, &Account{},
+		&JournalEntry{}, &JournalLine{}, &RefundSettings{}, &RefundRequest{}, &Menu{}, &Income{},
+		&DailyFoodRevenue{}, &RoomPrices{}, &DailyClose{}, &RoomCharge{}, &GroupReservation{}, &GroupRoom{},
+		&GuestProfile{}, &LoyaltyTransaction{},
+	}
+
+	moneyType := reflect.TypeOf(Money{})
+	want := map[string]int{}
+	for _, model := range models {
+		parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("schema.Parse(%T): %v", model, err)
+		}
+		for _, field := range parsed.Fields {
+			if field.DBName == "" || field.IndirectFieldType != moneyType {
+				continue
+			}
+			want["UPDATE "+parsed.Table+" SET "+field.DBName+" = ROUND("+field.DBName+" * 100)"]++
+		}
+	}
+
+	got := map[string]int{}
+	for _, statement := range moneyMinorUnitUpdates(func(table, column string) bool { return true }) {
+		got[statement]++
+	}
+
+	for statement, count := range got {
+		if count != 1 {
+			t.Errorf("%q runs %d times; want once", statement, count)
+		}
+		if want[statement] == 0 {
+			t.Errorf("%q doesn't scale a money column", statement)
+		}
+	}
+	var missing []string
+	for statement := range want {
+		if got[statement] == 0 {
+			missing = append(missing, statement)
+		}
+	}
+	sort.Strings(missing)
+	for _, statement := range missing {
+		t.Errorf("money column not scaled: %q", statement)
+	}
+
+	present := moneyMinorUnitUpdates(func(table, column string) bool { return table == "incomes" && column == "amount" })
+	if len(present) != 1 || present[0] != "UPDATE incomes SET amount = ROUND(amount * 100)" {
+		t.Errorf("updates with only incomes.amount present = %q", present)
+	}
+}
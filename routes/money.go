@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minorPerMajor is how many minor units make up one unit of a currency. Every currency is kept in
+// hundredths: kyat in pya and dollars in cents. A currency with no minor unit would just always hold
+// whole hundreds.
+const minorPerMajor = 100
+
+// Money is an amount in whole minor units of a currency. It is stored in a single BIGINT column holding
+// the minor units; the currency isn't stored with it, because every column is in kyat except the
+// original amounts of income and payments, which have their own currency column.
+//
+// Rounding rules:
+//   - Amounts given in major units, in JSON or as a decimal string, are rounded to the nearest minor unit,
+//     halves away from zero: "0.125" is 0.13 and "-0.125" is -0.13.
+//   - Multiplying by a rate or a percentage (Mul) rounds the same way, once, on the result.
+//   - Charges the hotel works out itself, such as service charge, commercial tax and converted foreign
+//     currency, are rounded to the whole kyat with Round, halves away from zero, as they were when
+//     amounts were kept in kyat.
+//   - Splitting an amount into shares (Share) rounds each share, and the caller puts any difference left
+//     over onto one line, so the shares always add back up to the amount.
+//   - Averages (Div), such as the average daily rate, round to the nearest minor unit, halves away from zero.
+//
+// Amounts a client sends are always in kyat; a request taking another currency has a Currency field of
+// its own. Adding or subtracting two different currencies gives an amount that can't be stored or written
+// out, so the mistake comes back as ErrCurrencyMismatch from whatever saves it.
+//
+// The zero value is nothing in kyat.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// kyat is a whole number of kyat
+func kyat(amount int64) Money {
+	return Money{Minor: amount * minorPerMajor, Currency: baseCurrency}
+}
+
+// moneyFromMajor converts an amount in major units, such as 12.5 dollars, rounding to the minor unit
+func moneyFromMajor(amount float64, currency string) Money {
+	return Money{Minor: roundHalfAway(amount * minorPerMajor), Currency: currency}
+}
+
+// roundHalfAway rounds to the nearest integer, halves away from zero
+func roundHalfAway(value float64) int64 {
+	return int64(math.Round(value))
+}
+
+// CurrencyCode is the currency, kyat when none was set
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return baseCurrency
+	}
+	return m.Currency
+}
+
+// In returns the same number of minor units in another currency, for columns whose currency is stored
+// separately
+func (m Money) In(currency string) Money {
+	m.Currency = currency
+	return m
+}
+
+// ErrCurrencyMismatch is returned when an amount that combined two different currencies is stored or
+// written out
+var ErrCurrencyMismatch = errors.New("money: can't combine amounts in different currencies")
+
+// mixedCurrencies is the currency of an amount that combined two different ones
+const mixedCurrencies = "mixed"
+
+func (m Money) combinedCurrency(other Money) string {
+	if m.Currency != "" && other.Currency != "" && m.Currency != other.Currency {
+		return mixedCurrencies
+	}
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return other.Currency
+}
+
+// Err is ErrCurrencyMismatch if the amount combined two different currencies
+func (m Money) Err() error {
+	if m.Currency == mixedCurrencies {
+		return ErrCurrencyMismatch
+	}
+	return nil
+}
+
+// Add returns the sum; see Err for combining two different currencies
+func (m Money) Add(other Money) Money {
+	return Money{Minor: m.Minor + other.Minor, Currency: m.combinedCurrency(other)}
+}
+
+// Sub returns the difference; see Err for combining two different currencies
+func (m Money) Sub(other Money) Money {
+	return Money{Minor: m.Minor - other.Minor, Currency: m.combinedCurrency(other)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Times multiplies by a whole quantity, which is exact
+func (m Money) Times(quantity int) Money {
+	return Money{Minor: m.Minor * int64(quantity), Currency: m.Currency}
+}
+
+// Mul multiplies by a rate or fraction, rounding the result to the minor unit
+func (m Money) Mul(factor float64) Money {
+	return Money{Minor: roundHalfAway(float64(m.Minor) * factor), Currency: m.Currency}
+}
+
+// Div divides by a count, such as room nights for an average rate, rounding to the minor unit; dividing
+// by zero gives nothing
+func (m Money) Div(count int) Money {
+	if count == 0 {
+		return Money{Currency: m.Currency}
+	}
+	return Money{Minor: roundHalfAway(float64(m.Minor) / float64(count)), Currency: m.Currency}
+}
+
+// Round rounds to the whole unit of the currency, halves away from zero
+func (m Money) Round() Money {
+	return Money{Minor: roundHalfAway(float64(m.Minor)/minorPerMajor) * minorPerMajor, Currency: m.Currency}
+}
+
+// Share is the part of the amount that part is of whole, rounded to the minor unit
+func (m Money) Share(part, whole Money) Money {
+	if part.Minor == whole.Minor {
+		return m
+	}
+	if whole.Minor == 0 {
+		return Money{Currency: m.Currency}
+	}
+	return m.Mul(float64(part.Minor) / float64(whole.Minor))
+}
+
+// Ratio is how many times other goes into the amount, for percentages and averages
+func (m Money) Ratio(other Money) float64 {
+	if other.Minor == 0 {
+		return 0
+	}
+	return float64(m.Minor) / float64(other.Minor)
+}
+
+// Major is the amount in major units, for display and for ratios; don't do arithmetic on it
+func (m Money) Major() float64 {
+	return float64(m.Minor) / minorPerMajor
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// Sign is -1, 0 or 1
+func (m Money) Sign() int {
+	switch {
+	case m.Minor < 0:
+		return -1
+	case m.Minor > 0:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Abs() Money {
+	if m.Minor < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// Decimal is the amount in major units written out exactly, such as "1500.00" or "-0.05"
+func (m Money) Decimal() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.CurrencyCode()
+}
+
+// minMoney is the smaller of two amounts
+func minMoney(a, b Money) Money {
+	if a.Minor < b.Minor {
+		return a
+	}
+	return b
+}
+
+// parseMajor reads a decimal amount in major units exactly, rounding any digits past the minor unit
+// halves away from zero
+func parseMajor(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if strings.ContainsAny(text, "eE") {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0, err
+		}
+		return roundHalfAway(value * minorPerMajor), nil
+	}
+
+	digits := text
+	negative := strings.HasPrefix(digits, "-")
+	if negative || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if (whole == "" && fraction == "") || !allDigits(whole) || !allDigits(fraction) {
+		return 0, fmt.Errorf("invalid amount %q", text)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	roundUp := len(fraction) > 2 && fraction[2] >= '5'
+	fraction = (fraction + "00")[:2]
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", text)
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+func allDigits(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMoney reads a decimal amount in major units of a currency
+func parseMoney(text, currency string) (Money, error) {
+	minor, err := parseMajor(text)
+	return Money{Minor: minor, Currency: currency}, err
+}
+
+// GormDataType stores Money as its minor units
+func (Money) GormDataType() string {
+	return "bigint"
+}
+
+// Value writes the minor units; the currency is kept in its own column where it can vary
+func (m Money) Value() (driver.Value, error) {
+	if err := m.Err(); err != nil {
+		return nil, err
+	}
+	return m.Minor, nil
+}
+
+// Scan reads minor units. SUM and AVG come back as decimals, which are rounded to the minor unit.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		m.Minor = 0
+	case int64:
+		m.Minor = v
+	case float64:
+		m.Minor = roundHalfAway(v)
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	default:
+		return fmt.Errorf("money: can't scan %T", value)
+	}
+	return nil
+}
+
+func (m *Money) scanText(text string) error {
+	if minor, err := strconv.ParseInt(text, 10, 64); err == nil {
+		m.Minor = minor
+		return nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("money: can't scan %q", text)
+	}
+	m.Minor = roundHalfAway(value)
+	return nil
+}
+
+// MarshalJSON writes the exact amount as a decimal string alongside the minor units and currency
+func (m Money) MarshalJSON() ([]byte, error) {
+	if err := m.Err(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Minor    int64  `json:"minor"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Minor, m.CurrencyCode()})
+}
+
+// UnmarshalJSON accepts a plain number or decimal string in major units, or the object MarshalJSON
+// writes, where the minor units win over the amount if both are given. Amounts come in in kyat, so the
+// object can't name another currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	switch {
+	case text == "null":
+		return nil
+	case strings.HasPrefix(text, "{"):
+		var object struct {
+			Amount   *json.RawMessage `json:"amount"`
+			Minor    *int64           `json:"minor"`
+			Currency string           `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+		currency := strings.ToUpper(strings.TrimSpace(object.Currency))
+		if currency != "" && currency != baseCurrency {
+			return fmt.Errorf("money: amounts are taken in %s, not %s; give another currency in the request's Currency field", baseCurrency, currency)
+		}
+		m.Currency = currency
+		if object.Minor != nil {
+			m.Minor = *object.Minor
+			return nil
+		}
+		if object.Amount == nil {
+			return errors.New("money: amount or minor is required")
+		}
+		return m.unmarshalMajor(*object.Amount)
+	}
+	return m.unmarshalMajor(data)
+}
+
+func (m *Money) unmarshalMajor(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	minor, err := parseMajor(text)
+	if err != nil {
+		return err
+	}
+	m.Minor = minor
+	return nil
+}
@@ -0,0 +1,227 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMajor(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{"0", 0},
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"0.05", 5},
+		{".5", 50},
+		{"+12", 1200},
+		{"0.125", 13},
+		{"-0.125", -13},
+		{"0.124", 12},
+		{"-0.124", -12},
+		{"1.995", 200},
+		{" 42.10 ", 4210},
+		{"1e3", 100000},
+	}
+	for _, test := range tests {
+		got, err := parseMajor(test.text)
+		if err != nil {
+			t.Errorf("parseMajor(%q): %v", test.text, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseMajor(%q) = %d; want %d", test.text, got, test.want)
+		}
+	}
+
+	for _, text := range []string{"", "-", ".", "abc", "1.2.3", "1,500", "1.2x", "1.234abc", "1.23456x", "-+5", "+-5", "--5", "5-", "1 000", "0x10", "1e", "e5"} {
+		if _, err := parseMajor(text); err == nil {
+			t.Errorf("parseMajor(%q) succeeded; want an error", text)
+		}
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		amount Money
+		factor float64
+		want   int64
+	}{
+		{Money{Minor: 1000}, 0.05, 50},
+		{Money{Minor: 10}, 0.25, 3},
+		{Money{Minor: -10}, 0.25, -3},
+		{Money{Minor: 10}, 0.24, 2},
+		{Money{Minor: 150000}, 1.1, 165000},
+		{Money{Minor: 150000}, 0, 0},
+	}
+	for _, test := range tests {
+		if got := test.amount.Mul(test.factor); got.Minor != test.want {
+			t.Errorf("%v.Mul(%v) = %d; want %d", test.amount, test.factor, got.Minor, test.want)
+		}
+	}
+}
+
+func TestMoneyDiv(t *testing.T) {
+	tests := []struct {
+		amount Money
+		count  int
+		want   int64
+	}{
+		{Money{Minor: 300}, 3, 100},
+		{Money{Minor: 100}, 3, 33},
+		{Money{Minor: 200}, 3, 67},
+		{Money{Minor: 5}, 2, 3},
+		{Money{Minor: -5}, 2, -3},
+		{Money{Minor: 500}, 0, 0},
+	}
+	for _, test := range tests {
+		if got := test.amount.Div(test.count); got.Minor != test.want {
+			t.Errorf("%v.Div(%d) = %d; want %d", test.amount, test.count, got.Minor, test.want)
+		}
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  int64
+	}{
+		{12345, 12300},
+		{12350, 12400},
+		{12349, 12300},
+		{-12350, -12400},
+		{-12349, -12300},
+		{50, 100},
+		{0, 0},
+	}
+	for _, test := range tests {
+		if got := (Money{Minor: test.minor}).Round(); got.Minor != test.want {
+			t.Errorf("Money{%d}.Round() = %d; want %d", test.minor, got.Minor, test.want)
+		}
+	}
+}
+
+func TestMoneyShare(t *testing.T) {
+	tests := []struct {
+		amount, part, whole Money
+		want                int64
+	}{
+		{kyat(100), kyat(50), kyat(200), 2500},
+		{kyat(100), kyat(1), kyat(3), 3333},
+		{kyat(100), kyat(2), kyat(3), 6667},
+		{kyat(100), kyat(3), kyat(3), 10000},
+		{kyat(100), kyat(0), kyat(0), 10000},
+		{kyat(100), kyat(1), kyat(0), 0},
+		{Money{Minor: 1}, Money{Minor: 1}, Money{Minor: 2}, 1},
+		{kyat(-100), kyat(1), kyat(4), -2500},
+	}
+	for _, test := range tests {
+		if got := test.amount.Share(test.part, test.whole); got.Minor != test.want {
+			t.Errorf("%v.Share(%v, %v) = %d; want %d", test.amount, test.part, test.whole, got.Minor, test.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int64
+	}{
+		{nil, 0},
+		{int64(150000), 150000},
+		{float64(123.5), 124},
+		{float64(-123.5), -124},
+		{[]byte("150000"), 150000},
+		{[]byte("123.5"), 124},
+		{[]byte("123.4999"), 123},
+		{"-0.5", -1},
+	}
+	for _, test := range tests {
+		amount := Money{Minor: 99}
+		if err := amount.Scan(test.value); err != nil {
+			t.Errorf("Scan(%#v): %v", test.value, err)
+			continue
+		}
+		if amount.Minor != test.want {
+			t.Errorf("Scan(%#v) = %d; want %d", test.value, amount.Minor, test.want)
+		}
+	}
+
+	var amount Money
+	if err := amount.Scan([]byte("abc")); err == nil {
+		t.Error("Scan accepted text that isn't a number")
+	}
+	if err := amount.Scan(true); err == nil {
+		t.Error("Scan accepted a bool")
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		want     int64
+		currency string
+	}{
+		{`1500`, 150000, ""},
+		{`1500.5`, 150050, ""},
+		{`0.125`, 13, ""},
+		{`"1500.50"`, 150050, ""},
+		{`"-0.125"`, -13, ""},
+		{`{"amount": "12.34"}`, 1234, ""},
+		{`{"amount": 12.34, "currency": "mmk"}`, 1234, "MMK"},
+		{`{"minor": 1234, "currency": "MMK"}`, 1234, "MMK"},
+		{`{"amount": "99.99", "minor": 1234}`, 1234, ""},
+		{`null`, 0, ""},
+	}
+	for _, test := range tests {
+		var amount Money
+		if err := json.Unmarshal([]byte(test.json), &amount); err != nil {
+			t.Errorf("Unmarshal(%s): %v", test.json, err)
+			continue
+		}
+		if amount.Minor != test.want || amount.Currency != test.currency {
+			t.Errorf("Unmarshal(%s) = %d %q; want %d %q", test.json, amount.Minor, amount.Currency, test.want, test.currency)
+		}
+	}
+
+	for _, text := range []string{`{}`, `{"currency": "MMK"}`, `{"amount": "12", "currency": "USD"}`, `"abc"`, `true`} {
+		var amount Money
+		if err := json.Unmarshal([]byte(text), &amount); err == nil {
+			t.Errorf("Unmarshal(%s) = %v; want an error", text, amount)
+		}
+	}
+
+	// MarshalJSON's output reads back as the same amount
+	data, err := json.Marshal(Money{Minor: -1234, Currency: baseCurrency})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var amount Money
+	if err := json.Unmarshal(data, &amount); err != nil || amount.Minor != -1234 {
+		t.Errorf("Unmarshal(%s) = %v, %v; want -12.34 MMK", data, amount, err)
+	}
+}
+
+func TestMoneyMixedCurrencies(t *testing.T) {
+	dollars := Money{Minor: 1000, Currency: "USD"}
+
+	if err := kyat(10).Add(Money{Minor: 5}).Err(); err != nil {
+		t.Errorf("adding an amount with no currency: %v", err)
+	}
+
+	mixed := kyat(10).Add(dollars)
+	if !errors.Is(mixed.Err(), ErrCurrencyMismatch) {
+		t.Fatalf("kyat plus dollars: Err() = %v; want ErrCurrencyMismatch", mixed.Err())
+	}
+	if err := mixed.Sub(kyat(1)).Err(); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("arithmetic on a mixed amount: Err() = %v; want ErrCurrencyMismatch", err)
+	}
+	if _, err := mixed.Value(); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Value() = %v; want ErrCurrencyMismatch", err)
+	}
+	if _, err := json.Marshal(mixed); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Marshal = %v; want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,252 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// ErrBusinessDateClosed is returned when a record belongs to a business date that the night audit has already closed
+var ErrBusinessDateClosed = errors.New("business date is closed")
+
+type DailyClose struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement"`
+	BusinessDate      time.Time `gorm:"type:date;uniqueIndex;not null"`
+	TotalRooms        int       `gorm:"not null;default:0"`
+	OccupiedRooms     int       `gorm:"not null;default:0"`
+	OccupancyRate     float64   `gorm:"not null;default:0"`
+	GuestsInHouse     int       `gorm:"not null;default:0"`
+	RoomChargesPosted Money     `gorm:"not null;default:0"`
+	RoomRevenue       Money     `gorm:"not null;default:0"`
+	FoodRevenue       Money     `gorm:"not null;default:0"`
+	OtherRevenue      Money     `gorm:"not null;default:0"`
+	TotalRevenue      Money     `gorm:"not null;default:0"`
+	ClosedBy          int       `gorm:"not null"`
+	ClosedAt          time.Time `gorm:"not null"`
+}
+
+type RoomCharge struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement"`
+	GuestID      int       `gorm:"not null;uniqueIndex:idx_room_charge_guest_date"`
+	RoomNumber   int       `gorm:"not null"`
+	RoomType     string    `gorm:"type:varchar(50);not null"`
+	BusinessDate time.Time `gorm:"type:date;not null;uniqueIndex:idx_room_charge_guest_date"`
+	Amount       Money     `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// dateOnly strips the clock from t, keeping its calendar date in UTC
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// currentBusinessDate is the day after the last closed business date, or today if no audit has run yet
+func currentBusinessDate(tx *gorm.DB) (time.Time, error) {
+	var last DailyClose
+	err := tx.Order("business_date DESC").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dateOnly(time.Now().UTC()), nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return dateOnly(last.BusinessDate).AddDate(0, 0, 1), nil
+}
+
+// checkBusinessDateOpen returns ErrBusinessDateClosed if the night audit has closed the given date
+func checkBusinessDateOpen(tx *gorm.DB, date time.Time) error {
+	var count int64
+	if err := tx.Model(&DailyClose{}).
+		Where("business_date = ?", dateOnly(date).Format("2006-01-02")).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBusinessDateClosed
+	}
+	return nil
+}
+
+// hookDB returns a fresh session on the hook's connection so lookups don't inherit the hooked statement
+func hookDB(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true})
+}
+
+func RunNightAudit(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	tx := DB.Begin()
+
+	businessDate, err := currentBusinessDate(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to determine business date"})
+		return
+	}
+
+	if businessDate.After(dateOnly(time.Now().UTC())) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Business date " + businessDate.Format("2006-01-02") + " has not started yet"})
+		return
+	}
+
+	// Post one night of room charges to every in-house guest
+	var guests []Guests
+	if err := tx.Where("status = ?", "ACTIVE").Find(&guests).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch in-house guests"})
+		return
+	}
+
+	prices := loadRoomPrices(tx)
+	taxSettings, err := loadTaxSettings(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax settings"})
+		return
+	}
+	var chargesPosted Money
+	for _, guest := range guests {
+		amount := prices.priceForRoomType(guest.RoomType)
+		if guest.ExtraBed {
+			amount = amount.Add(prices.ExtraBed)
+		}
+
+		charge := RoomCharge{
+			GuestID:      guest.ID,
+			RoomNumber:   guest.RoomNumber,
+			RoomType:     guest.RoomType,
+			BusinessDate: businessDate,
+			Amount:       amount,
+		}
+		if err := tx.Create(&charge).Error; err != nil {
+			tx.Rollback()
+			fmt.Printf("Error posting room charge for guest %d: %v\n", guest.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post room charges"})
+			return
+		}
+
+		if err := tx.Model(&guest).Update("room_charges", gorm.Expr("room_charges + ?", amount)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update guest room charges"})
+			return
+		}
+
+		split := taxSettings.split("ROOM", amount)
+		if err := recordTax(tx, &guest.ID, "ROOM", "ROOM_CHARGE", charge.ID, businessDate, split); err != nil {
+			tx.Rollback()
+			fmt.Printf("Error recording room charge tax for guest %d: %v\n", guest.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post room charge tax"})
+			return
+		}
+
+		description := fmt.Sprintf("Room %d night of %s", guest.RoomNumber, businessDate.Format("2006-01-02"))
+		if err := postFolioCharge(tx, "ROOM_CHARGE", charge.ID, accountRoomRevenue, split, description); err != nil {
+			tx.Rollback()
+			fmt.Printf("Error posting room charge to the ledger for guest %d: %v\n", guest.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to post room charges to the ledger"})
+			return
+		}
+		chargesPosted = chargesPosted.Add(amount)
+	}
+
+	// Snapshot occupancy
+	var rooms []Rooms
+	if err := tx.Find(&rooms).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch rooms"})
+		return
+	}
+
+	dailyClose := DailyClose{
+		BusinessDate:      businessDate,
+		TotalRooms:        len(rooms),
+		GuestsInHouse:     len(guests),
+		RoomChargesPosted: chargesPosted,
+		ClosedBy:          adminID,
+		ClosedAt:          time.Now().UTC(),
+	}
+	for _, room := range rooms {
+		if room.Status >= 2 && room.Status <= 4 {
+			dailyClose.OccupiedRooms++
+		}
+	}
+	if dailyClose.TotalRooms > 0 {
+		dailyClose.OccupancyRate = float64(dailyClose.OccupiedRooms) / float64(dailyClose.TotalRooms) * 100
+	}
+
+	// Snapshot revenue for the day
+	var revenue struct {
+		RoomRevenue  Money
+		FoodRevenue  Money
+		OtherRevenue Money
+		TotalRevenue Money
+	}
+	if err := tx.Model(&Income{}).
+		Select(`
+			COALESCE(SUM(CASE WHEN type = 'room' THEN amount ELSE 0 END), 0) as room_revenue,
+			COALESCE(SUM(CASE WHEN type = 'food' THEN amount ELSE 0 END), 0) as food_revenue,
+			COALESCE(SUM(CASE WHEN type NOT IN ('room', 'food') THEN amount ELSE 0 END), 0) as other_revenue,
+			COALESCE(SUM(amount), 0) as total_revenue
+		`).
+		Where("COALESCE(business_date, DATE(created_at)) = ?", businessDate.Format("2006-01-02")).
+		Scan(&revenue).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch revenue for the business date"})
+		return
+	}
+	dailyClose.RoomRevenue = revenue.RoomRevenue
+	dailyClose.FoodRevenue = revenue.FoodRevenue
+	dailyClose.OtherRevenue = revenue.OtherRevenue
+	dailyClose.TotalRevenue = revenue.TotalRevenue
+
+	// Closing the date locks it and rolls the business date forward
+	if err := tx.Create(&dailyClose).Error; err != nil {
+		tx.Rollback()
+		fmt.Printf("Error creating daily close: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to close business date"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to close business date"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Night audit completed successfully",
+		"dailyClose":       dailyClose,
+		"nextBusinessDate": businessDate.AddDate(0, 0, 1).Format("2006-01-02"),
+	})
+}
+
+func GetBusinessDate(c *gin.Context) {
+	businessDate, err := currentBusinessDate(DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to determine business date"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"businessDate": businessDate.Format("2006-01-02")})
+}
+
+func GetDailyClose(c *gin.Context) {
+	date := c.Param("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	var dailyClose DailyClose
+	if err := DB.Where("business_date = ?", date).First(&dailyClose).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Business date has not been closed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch daily close"})
+		return
+	}
+	c.JSON(http.StatusOK, dailyClose)
+}
@@ -0,0 +1,479 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// Payment is one amount taken towards a stay or a reservation; a bill can be settled by several of them
+type Payment struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement"`
+	GuestID           *int      `gorm:"null;index"`
+	ReservationID     *int      `gorm:"null;index"`
+	Amount            Money     `gorm:"not null"`                               // MMK equivalent at the time it was taken
+	Currency          string    `gorm:"type:varchar(3);not null;default:'MMK'"` // Currency the guest paid in
+	OriginalAmount    Money     `gorm:"not null;default:0"`                     // Amount in that currency
+	ExchangeRate      float64   `gorm:"not null;default:1"`                     // MMK per unit it was converted at
+	Method            string    `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');not null"`
+	Reference         *string   `gorm:"null"` // Wallet transaction number or receipt number
+	TakenBy           *int      `gorm:"null"` // Receptionist who took the payment
+	IncomeID          *uint     `gorm:"null;index"`
+	IncomeType        string    `gorm:"type:varchar(50);not null;default:'room'"` // Income type it is booked to once confirmed
+	Status            string    `gorm:"type:enum('PENDING','CONFIRMED','FAILED');not null;default:'CONFIRMED'"`
+	ProviderReference *string   `gorm:"type:varchar(100);null;index"` // The wallet's ID for a payment made through a provider
+	PaidAt            time.Time `gorm:"type:datetime;not null"`
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+}
+
+// BeforeCreate fills in the currency for payments taken in kyat, which is every payment that doesn't say
+func (payment *Payment) BeforeCreate(tx *gorm.DB) error {
+	if payment.Currency == "" || payment.Currency == baseCurrency {
+		payment.Currency = baseCurrency
+		payment.OriginalAmount = payment.Amount
+		payment.ExchangeRate = 1
+	}
+	payment.OriginalAmount.Currency = payment.Currency
+	return nil
+}
+
+// AfterFind labels the original amount with the currency it was paid in
+func (payment *Payment) AfterFind(tx *gorm.DB) error {
+	payment.OriginalAmount.Currency = payment.Currency
+	return nil
+}
+
+type paymentRequest struct {
+	Amount    Money  // In Currency
+	Currency  string // Defaults to MMK
+	Method    string
+	Reference *string
+	Type      string // Income type the payment is booked to: room, food or other; defaults to room
+}
+
+// validate checks a payment request and fills in its defaults
+func (request *paymentRequest) validate() error {
+	if request.Amount.Sign() <= 0 {
+		return errors.New("payment amount must be greater than zero")
+	}
+	if !paymentMethods[request.Method] {
+		return errors.New("payment method must be one of CASH, KPAY, AYAPAY or WAVEPAY")
+	}
+	switch request.Type {
+	case "":
+		request.Type = "room"
+	case "room", "food", "other":
+	default:
+		return fmt.Errorf("unknown income type %q", request.Type)
+	}
+	return nil
+}
+
+// recordPayment saves a payment taken at the desk along with the Income row that books it as revenue, so
+// the revenue summary's cash and online split is built from the individual payments
+func recordPayment(tx *gorm.DB, payment *Payment, incomeType string, roomNumber int) error {
+	if payment.PaidAt.IsZero() {
+		payment.PaidAt = time.Now().UTC()
+	}
+	payment.IncomeType = incomeType
+	payment.Status = "CONFIRMED"
+
+	// Foreign currency is converted at the rate in effect on the business date it is booked to, and keeps
+	// that rate even if the rate changes later
+	if payment.Currency != "" && payment.Currency != baseCurrency {
+		businessDate, err := currentBusinessDate(tx)
+		if err != nil {
+			return err
+		}
+		amount, rate, err := convertToMMK(tx, payment.OriginalAmount, businessDate)
+		if err != nil {
+			return err
+		}
+		payment.Amount = amount
+		payment.ExchangeRate = rate
+	}
+
+	if err := bookPaymentIncome(tx, payment, roomNumber); err != nil {
+		return err
+	}
+	if err := tx.Create(payment).Error; err != nil {
+		return err
+	}
+	return syncPaymentTotals(tx, payment)
+}
+
+// confirmPayment books a pending payment, such as a wallet payment, once the money has arrived
+func confirmPayment(tx *gorm.DB, payment *Payment) error {
+	roomNumber := 0
+	if payment.GuestID != nil {
+		var guest Guests
+		if err := tx.First(&guest, *payment.GuestID).Error; err != nil {
+			return err
+		}
+		roomNumber = guest.RoomNumber
+	}
+
+	payment.Status = "CONFIRMED"
+	payment.PaidAt = time.Now().UTC()
+	if err := bookPaymentIncome(tx, payment, roomNumber); err != nil {
+		return err
+	}
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"status":    payment.Status,
+		"paid_at":   payment.PaidAt,
+		"income_id": payment.IncomeID,
+	}).Error; err != nil {
+		return err
+	}
+	return syncPaymentTotals(tx, payment)
+}
+
+// bookPaymentIncome creates the Income row for a payment and earns any loyalty points on it
+func bookPaymentIncome(tx *gorm.DB, payment *Payment, roomNumber int) error {
+	income := Income{
+		Type:           payment.IncomeType,
+		RoomNumber:     roomNumber,
+		Amount:         payment.Amount,
+		Currency:       payment.Currency,
+		OriginalAmount: payment.OriginalAmount,
+		ExchangeRate:   payment.ExchangeRate,
+		RevenueType:    "revenue",
+		PaymentMethod:  payment.Method,
+		CreatedAt:      payment.PaidAt,
+	}
+	if payment.GuestID != nil {
+		guestID := uint(*payment.GuestID)
+		income.GuestID = &guestID
+	}
+	if err := attributeIncome(tx, &income, payment.TakenBy); err != nil {
+		return err
+	}
+	if err := tx.Create(&income).Error; err != nil {
+		return err
+	}
+	counterCode := incomeCounterAccount(income)
+	if payment.GuestID == nil && payment.ReservationID != nil {
+		counterCode = accountGuestDeposits
+	}
+	if err := postIncomeJournal(tx, income, counterCode); err != nil {
+		return err
+	}
+	if err := accrueLoyaltyPoints(tx, income); err != nil {
+		return err
+	}
+	payment.IncomeID = &income.ID
+	return nil
+}
+
+// syncPaymentTotals updates the amount paid on the stay or reservation a payment belongs to
+func syncPaymentTotals(tx *gorm.DB, payment *Payment) error {
+	if payment.GuestID != nil {
+		return syncAmountPaid(tx, &Guests{}, "guest_id", *payment.GuestID, payment.Method)
+	}
+	if payment.ReservationID != nil {
+		return syncAmountPaid(tx, &Reservation{}, "reservation_id", *payment.ReservationID, payment.Method)
+	}
+	return nil
+}
+
+// syncAmountPaid keeps the AmountPaid and PaymentType columns, which older screens still read, in step
+// with the confirmed payments. PaymentType becomes the method of the latest payment.
+func syncAmountPaid(tx *gorm.DB, model interface{}, column string, id int, method string) error {
+	var paid Money
+	if err := tx.Model(&Payment{}).
+		Where(column+" = ? AND status = ?", id, "CONFIRMED").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return err
+	}
+	return tx.Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+		"amount_paid":  paid,
+		"payment_type": method,
+	}).Error
+}
+
+// transferDeposits moves the deposits taken on a reservation onto the stay it was checked in as. The
+// money leaves guest deposits for the guest ledger, posted against each deposit's income so a later
+// refund of it comes off the guest ledger.
+func transferDeposits(tx *gorm.DB, reservationID int, guest Guests) error {
+	var deposits []Payment
+	if err := tx.Where("reservation_id = ? AND guest_id IS NULL AND status = ?", reservationID, "CONFIRMED").
+		Find(&deposits).Error; err != nil {
+		return err
+	}
+	if len(deposits) == 0 {
+		return nil
+	}
+
+	guestID := uint(guest.ID)
+	for _, deposit := range deposits {
+		if err := tx.Model(&deposit).Update("guest_id", guest.ID).Error; err != nil {
+			return err
+		}
+		if deposit.IncomeID == nil {
+			continue
+		}
+		// Linking the income to the stay changes none of its amounts, so it doesn't go through the
+		// closed business date check
+		if err := tx.Model(&Income{}).Where("id = ?", *deposit.IncomeID).UpdateColumns(map[string]interface{}{
+			"guest_id":    guestID,
+			"room_number": guest.RoomNumber,
+		}).Error; err != nil {
+			return err
+		}
+		// Whatever of the deposit is still held, after any refund taken from it before arrival
+		balances, err := sourceBalances(tx, "INCOME", *deposit.IncomeID)
+		if err != nil {
+			return err
+		}
+		held := balances[accountGuestDeposits].Neg()
+		if held.Sign() <= 0 {
+			continue
+		}
+		entry := JournalEntry{
+			Description: fmt.Sprintf("Income #%d: deposit on reservation #%d moved to room %d", *deposit.IncomeID, reservationID, guest.RoomNumber),
+			SourceType:  "INCOME",
+			SourceID:    deposit.IncomeID,
+		}
+		if err := postJournal(tx, &entry, []ledgerLine{
+			debit(accountGuestDeposits, held),
+			credit(accountGuestLedger, held),
+		}); err != nil {
+			return err
+		}
+	}
+	return syncAmountPaid(tx, &Guests{}, "guest_id", guest.ID, deposits[len(deposits)-1].Method)
+}
+
+// stayBalance totals what a stay has been charged and what has been paid towards it; pending wallet
+// payments don't count until the wallet confirms them
+func stayBalance(tx *gorm.DB, guest Guests) (charges Money, paid Money, err error) {
+	charges = guest.RoomCharges.Add(guest.FoodCharges).Add(guest.ExtraCharges).Add(guest.ServiceCharges).Add(guest.TaxCharges).Sub(guest.LoyaltyDiscount)
+	err = tx.Model(&Payment{}).
+		Where("guest_id = ? AND status = ?", guest.ID, "CONFIRMED").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error
+	return charges, paid, err
+}
+
+// respondPaymentError reports why a payment couldn't be recorded
+func respondPaymentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrBusinessDateClosed):
+		c.JSON(http.StatusConflict, gin.H{"message": "Business date is closed"})
+	case errors.Is(err, ErrNoOpenShift):
+		c.JSON(http.StatusConflict, gin.H{"message": "Open a cashier shift before taking cash"})
+	case errors.Is(err, ErrNoExchangeRate):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+	default:
+		fmt.Printf("Error recording payment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": message})
+	}
+}
+
+// receptionistID is the receptionist signed in on a request under AuthMiddleware, if any
+func receptionistID(c *gin.Context) *int {
+	id := int(c.GetFloat64("user_id"))
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func AddGuestPayment(c *gin.Context) {
+	id := c.Param("id")
+
+	var request paymentRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := checkCurrency(DB, &request.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var guest Guests
+	if err := tx.First(&guest, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+
+	payment := Payment{
+		GuestID:        &guest.ID,
+		Amount:         request.Amount,
+		Currency:       request.Currency,
+		OriginalAmount: request.Amount.In(request.Currency),
+		Method:         request.Method,
+		Reference:      request.Reference,
+		TakenBy:        receptionistID(c),
+	}
+	if err := recordPayment(tx, &payment, request.Type, guest.RoomNumber); err != nil {
+		tx.Rollback()
+		respondPaymentError(c, err, "Failed to record payment")
+		return
+	}
+
+	if err := tx.First(&guest, guest.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to record payment"})
+		return
+	}
+	charges, paid, err := stayBalance(tx, guest)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate balance"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment recorded successfully",
+		"payment": payment,
+		"charges": charges,
+		"paid":    paid,
+		"balance": charges.Sub(paid),
+	})
+}
+
+func GetGuestPayments(c *gin.Context) {
+	id := c.Param("id")
+
+	var payments []Payment
+	if err := DB.Where("guest_id = ?", id).Order("paid_at").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payments"})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// GetGuestBalance returns what a stay owes, worked out from its charges and the payments taken
+func GetGuestBalance(c *gin.Context) {
+	id := c.Param("id")
+
+	var guest Guests
+	if err := DB.First(&guest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+
+	charges, paid, err := stayBalance(DB, guest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate balance"})
+		return
+	}
+
+	var byMethod []struct {
+		Method string `json:"method"`
+		Amount Money  `json:"amount"`
+	}
+	if err := DB.Model(&Payment{}).
+		Select("method, SUM(amount) as amount").
+		Where("guest_id = ? AND status = ?", guest.ID, "CONFIRMED").
+		Group("method").
+		Scan(&byMethod).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"guestId":        guest.ID,
+		"roomCharges":    guest.RoomCharges,
+		"foodCharges":    guest.FoodCharges,
+		"extraCharges":   guest.ExtraCharges,
+		"serviceCharges": guest.ServiceCharges,
+		"taxCharges":     guest.TaxCharges,
+		"discount":       guest.LoyaltyDiscount,
+		"charges":        charges,
+		"paid":           paid,
+		"balance":        charges.Sub(paid),
+		"byMethod":       byMethod,
+	})
+}
+
+// AddReservationPayment records a deposit taken against a reservation
+func AddReservationPayment(c *gin.Context) {
+	id := c.Param("id")
+
+	var request paymentRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := checkCurrency(DB, &request.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var reservation Reservation
+	if err := tx.First(&reservation, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Reservation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reservation"})
+		return
+	}
+	if reservation.Status == "CANCELLED" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Reservation is cancelled"})
+		return
+	}
+
+	payment := Payment{
+		ReservationID:  &reservation.ID,
+		Amount:         request.Amount,
+		Currency:       request.Currency,
+		OriginalAmount: request.Amount.In(request.Currency),
+		Method:         request.Method,
+		Reference:      request.Reference,
+		TakenBy:        receptionistID(c),
+	}
+	if err := recordPayment(tx, &payment, request.Type, 0); err != nil {
+		tx.Rollback()
+		respondPaymentError(c, err, "Failed to record payment")
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment recorded successfully",
+		"payment": payment,
+	})
+}
+
+func GetReservationPayments(c *gin.Context) {
+	id := c.Param("id")
+
+	var payments []Payment
+	if err := DB.Where("reservation_id = ?", id).Order("paid_at").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payments"})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
@@ -0,0 +1,335 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrProviderNotConfigured is returned when a wallet has no enabled provider set up for the hotel
+var ErrProviderNotConfigured = errors.New("payment provider not configured")
+
+// PaymentProvider is a mobile wallet the hotel can take payments through
+type PaymentProvider interface {
+	// CreatePayment starts a payment with the wallet and returns its reference and the QR code payload the guest scans
+	CreatePayment(request WalletPaymentRequest) (WalletPaymentSession, error)
+	// VerifyWebhook checks a webhook call really came from the wallet and reads the payment update out of it
+	VerifyWebhook(header http.Header, body []byte) (WalletPaymentUpdate, error)
+	// QueryStatus asks the wallet for the current status of a payment
+	QueryStatus(reference string) (string, error)
+}
+
+type WalletPaymentRequest struct {
+	PaymentID   uint
+	Amount      Money
+	Description string
+}
+
+type WalletPaymentSession struct {
+	Reference string // The wallet's own ID for the payment
+	QRPayload string // Content of the QR code shown to the guest
+	ExpiresAt time.Time
+}
+
+type WalletPaymentUpdate struct {
+	Reference string
+	Status    string // CONFIRMED or FAILED; anything else leaves the payment pending
+}
+
+// PaymentProviderConfig is the hotel's set-up for one wallet
+type PaymentProviderConfig struct {
+	Method     string    `json:"method" gorm:"primaryKey;type:varchar(20)"` // KPAY, AYAPAY or WAVEPAY
+	Provider   string    `json:"provider" gorm:"not null"`                  // Registered provider implementation
+	MerchantID string    `json:"merchantId"`
+	Secret     string    `json:"-"` // Shared secret for signing requests and webhooks
+	Enabled    bool      `json:"enabled" gorm:"not null;default:false"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// paymentProviderFactories builds a provider from the hotel's config, keyed by provider name
+var paymentProviderFactories = map[string]func(config PaymentProviderConfig) PaymentProvider{}
+
+// RegisterPaymentProvider makes a provider implementation available to be configured for a wallet
+func RegisterPaymentProvider(name string, factory func(config PaymentProviderConfig) PaymentProvider) {
+	paymentProviderFactories[name] = factory
+}
+
+// walletMethods are the payment methods that go through a provider rather than the till
+var walletMethods = map[string]bool{
+	"KPAY":    true,
+	"AYAPAY":  true,
+	"WAVEPAY": true,
+}
+
+// providerFor returns the provider configured for a wallet payment method
+func providerFor(tx *gorm.DB, method string) (PaymentProvider, error) {
+	var config PaymentProviderConfig
+	if err := tx.First(&config, "method = ?", method).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w for %s", ErrProviderNotConfigured, method)
+		}
+		return nil, err
+	}
+	if !config.Enabled {
+		return nil, fmt.Errorf("%w for %s", ErrProviderNotConfigured, method)
+	}
+	factory, ok := paymentProviderFactories[config.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown provider %q for %s", ErrProviderNotConfigured, config.Provider, method)
+	}
+	return factory(config), nil
+}
+
+// applyWalletStatus moves a pending wallet payment on to the status the wallet reported. A webhook and a
+// status check can report the same payment at once, so the move is made only if the payment is still
+// pending when its row is updated; whichever comes second finds it already moved and leaves it.
+func applyWalletStatus(tx *gorm.DB, payment *Payment, status string) error {
+	if payment.Status != "PENDING" || (status != "CONFIRMED" && status != "FAILED") {
+		return nil
+	}
+	result := tx.Model(&Payment{}).
+		Where("id = ? AND status = ?", payment.ID, "PENDING").
+		Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return tx.First(payment, payment.ID).Error
+	}
+	if status == "CONFIRMED" {
+		return confirmPayment(tx, payment)
+	}
+	payment.Status = status
+	return nil
+}
+
+// StartWalletPayment asks the guest's wallet for a payment and returns the QR code for them to scan.
+// The payment stays pending, and off the bill, until the wallet confirms it.
+func StartWalletPayment(c *gin.Context) {
+	id := c.Param("id")
+
+	var request paymentRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if err := request.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !walletMethods[request.Method] {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Payment method must be one of KPAY, AYAPAY or WAVEPAY"})
+		return
+	}
+	if request.Currency != "" && request.Currency != baseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Wallet payments can only be taken in MMK"})
+		return
+	}
+
+	var guest Guests
+	if err := DB.First(&guest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+
+	provider, err := providerFor(DB, request.Method)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	payment := Payment{
+		GuestID:    &guest.ID,
+		Amount:     request.Amount,
+		Method:     request.Method,
+		IncomeType: request.Type,
+		Status:     "PENDING",
+		TakenBy:    receptionistID(c),
+		PaidAt:     time.Now().UTC(),
+	}
+	if err := DB.Create(&payment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create payment"})
+		return
+	}
+
+	session, err := provider.CreatePayment(WalletPaymentRequest{
+		PaymentID:   payment.ID,
+		Amount:      payment.Amount,
+		Description: fmt.Sprintf("Room %d - %s", guest.RoomNumber, guest.Name),
+	})
+	if err != nil {
+		DB.Model(&payment).Update("status", "FAILED")
+		fmt.Printf("Error creating %s payment: %v\n", payment.Method, err)
+		c.JSON(http.StatusBadGateway, gin.H{"message": "Failed to start wallet payment"})
+		return
+	}
+
+	payment.ProviderReference = &session.Reference
+	if err := DB.Model(&payment).Update("provider_reference", session.Reference).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save payment reference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Wallet payment started",
+		"payment":   payment,
+		"qrPayload": session.QRPayload,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// WalletWebhook receives payment updates from a wallet and confirms the matching payment
+func WalletWebhook(c *gin.Context) {
+	method := c.Param("method")
+
+	provider, err := providerFor(DB, method)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Failed to read request body"})
+		return
+	}
+
+	update, err := provider.VerifyWebhook(c.Request.Header, body)
+	if err != nil {
+		fmt.Printf("Rejected %s webhook: %v\n", method, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid webhook signature"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	var payment Payment
+	if err := tx.Where("method = ? AND provider_reference = ?", method, update.Reference).First(&payment).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payment"})
+		return
+	}
+
+	if err := applyWalletStatus(tx, &payment, update.Status); err != nil {
+		tx.Rollback()
+		fmt.Printf("Error applying %s webhook: %v\n", method, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update payment"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed", "status": payment.Status})
+}
+
+// GetPaymentStatus returns a payment's status, checking with the wallet while it is still pending
+func GetPaymentStatus(c *gin.Context) {
+	id := c.Param("id")
+
+	tx := DB.Begin()
+
+	var payment Payment
+	if err := tx.First(&payment, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payment"})
+		return
+	}
+
+	if payment.Status == "PENDING" && payment.ProviderReference != nil {
+		provider, err := providerFor(tx, payment.Method)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		status, err := provider.QueryStatus(*payment.ProviderReference)
+		if err != nil {
+			tx.Rollback()
+			fmt.Printf("Error querying %s payment: %v\n", payment.Method, err)
+			c.JSON(http.StatusBadGateway, gin.H{"message": "Failed to check payment with the wallet"})
+			return
+		}
+		if err := applyWalletStatus(tx, &payment, status); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update payment"})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, payment)
+}
+
+func GetPaymentProviders(c *gin.Context) {
+	var configs []PaymentProviderConfig
+	if err := DB.Order("method").Find(&configs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payment providers"})
+		return
+	}
+
+	available := make([]string, 0, len(paymentProviderFactories))
+	for name := range paymentProviderFactories {
+		available = append(available, name)
+	}
+	sort.Strings(available)
+
+	c.JSON(http.StatusOK, gin.H{
+		"configs":   configs,
+		"available": available,
+	})
+}
+
+// UpdatePaymentProvider sets up the provider used for one wallet
+func UpdatePaymentProvider(c *gin.Context) {
+	method := c.Param("method")
+	if !walletMethods[method] {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Method must be one of KPAY, AYAPAY or WAVEPAY"})
+		return
+	}
+
+	var request struct {
+		Provider   string  `json:"provider"`
+		MerchantID string  `json:"merchantId"`
+		Secret     *string `json:"secret"` // Left unchanged when not sent
+		Enabled    bool    `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if _, ok := paymentProviderFactories[request.Provider]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown provider " + request.Provider})
+		return
+	}
+
+	config := PaymentProviderConfig{Method: method}
+	DB.First(&config, "method = ?", method)
+	config.Provider = request.Provider
+	config.MerchantID = request.MerchantID
+	config.Enabled = request.Enabled
+	if request.Secret != nil {
+		config.Secret = *request.Secret
+	}
+
+	if err := DB.Save(&config).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save payment provider"})
+		return
+	}
+	c.JSON(http.StatusOK, config)
+}
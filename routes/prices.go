@@ -2,36 +2,55 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
 )
 
 type RoomPrices struct {
-	ID           int     `json:"id" gorm:"primaryKey"`
-	BNFP         float64 `json:"bnfp"`         // Full Night Price
-	BCFP         float64 `json:"bcfp"`         // Caution Price
-	BSFP         float64 `json:"bsfp"`         // Session Price
-	ExtraBed     float64 `json:"ebed"`         // Extra Bed Price
-	HourlyRate   float64 `json:"eachHour"`     // Hourly Rate
-	FamilyRoomFP float64 `json:"familyRoomFp"` // Family Room Full Night Price
+	ID           int   `json:"id" gorm:"primaryKey"`
+	BNFP         Money `json:"bnfp"`         // Full Night Price
+	BCFP         Money `json:"bcfp"`         // Caution Price
+	BSFP         Money `json:"bsfp"`         // Session Price
+	ExtraBed     Money `json:"ebed"`         // Extra Bed Price
+	HourlyRate   Money `json:"eachHour"`     // Hourly Rate
+	FamilyRoomFP Money `json:"familyRoomFp"` // Family Room Full Night Price
 }
 
-// GetRoomPrices retrieves the current room prices
-func GetRoomPrices(c *gin.Context) {
+// loadRoomPrices returns the stored room prices, creating the defaults if none exist yet
+func loadRoomPrices(tx *gorm.DB) RoomPrices {
 	var prices RoomPrices
-	result := DB.First(&prices)
+	result := tx.First(&prices)
 	if result.Error != nil {
 		// If no prices exist, return default prices
 		prices = RoomPrices{
-			BNFP:         63000,
-			BCFP:         42000,
-			BSFP:         30000,
-			ExtraBed:     20000,
-			HourlyRate:   10000,
-			FamilyRoomFP: 73000, // Higher price for family rooms
+			BNFP:         kyat(63000),
+			BCFP:         kyat(42000),
+			BSFP:         kyat(30000),
+			ExtraBed:     kyat(20000),
+			HourlyRate:   kyat(10000),
+			FamilyRoomFP: kyat(73000), // Higher price for family rooms
 		}
 		// Create default prices in database
-		DB.Create(&prices)
+		tx.Create(&prices)
+	}
+	return prices
+}
+
+// priceForRoomType returns the base price of a stay of the given room type
+func (prices RoomPrices) priceForRoomType(roomType string) Money {
+	switch roomType {
+	case "DAY-CAUTION":
+		return prices.BCFP
+	case "SESSION":
+		return prices.BSFP
+	default:
+		return prices.BNFP
 	}
+}
+
+// GetRoomPrices retrieves the current room prices
+func GetRoomPrices(c *gin.Context) {
+	prices := loadRoomPrices(DB)
 	c.JSON(http.StatusOK, prices)
 }
 
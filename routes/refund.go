@@ -0,0 +1,393 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RefundSettings holds the refund policy; there is a single row, created with the defaults on first use
+type RefundSettings struct {
+	ID                int   `json:"id" gorm:"primaryKey"`
+	ApprovalThreshold Money `json:"approvalThreshold"` // Refunds and voids above this amount need an admin
+}
+
+// RefundRequest is a refund or void of an income record. Small ones are processed straight away; the rest
+// wait for an admin to approve them.
+type RefundRequest struct {
+	ID              uint       `gorm:"primaryKey;autoIncrement"`
+	IncomeID        uint       `gorm:"not null;index"`
+	Kind            string     `gorm:"type:enum('REFUND','VOID');not null"`
+	Amount          Money      `gorm:"not null"`
+	Reason          string     `gorm:"type:text;not null"`
+	Status          string     `gorm:"type:enum('PENDING','APPROVED','REJECTED');not null;default:'PENDING'"`
+	RequestedBy     *int       `gorm:"null"` // Receptionist who asked for it
+	DecidedBy       *int       `gorm:"null"` // Admin who approved or rejected it, empty when under the threshold
+	CounterIncomeID *uint      `gorm:"null"` // Negative income row created when it was processed
+	RejectReason    *string    `gorm:"type:text;null"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	DecidedAt       *time.Time `gorm:"type:datetime"`
+}
+
+// loadRefundSettings returns the stored refund settings, creating the defaults if none exist yet
+func loadRefundSettings(tx *gorm.DB) RefundSettings {
+	var settings RefundSettings
+	if err := tx.First(&settings).Error; err != nil {
+		settings = RefundSettings{ApprovalThreshold: kyat(50000)}
+		tx.Create(&settings)
+	}
+	return settings
+}
+
+// refundableAmount is what is left of an income record after the counter-entries already posted against
+// it, for processed refunds and voids and for walk-in orders that came down, and its pending refunds
+func refundableAmount(tx *gorm.DB, income Income) (Money, error) {
+	var pending, reversed Money
+	if err := tx.Model(&RefundRequest{}).
+		Where("income_id = ? AND status = ?", income.ID, "PENDING").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&pending).Error; err != nil {
+		return Money{}, err
+	}
+	if err := tx.Model(&Income{}).
+		Where("reversal_of_id = ?", income.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&reversed).Error; err != nil {
+		return Money{}, err
+	}
+	return income.Amount.Add(reversed).Sub(pending), nil
+}
+
+// processRefund books a refund or void as a negative income row linked to the original, gives the money
+// back on the stay's payments, and takes back the loyalty points the income earned, all of them for a void
+// and the refunded share of them for a refund
+func processRefund(tx *gorm.DB, request *RefundRequest, adminID *int) error {
+	var original Income
+	if err := tx.First(&original, request.IncomeID).Error; err != nil {
+		return err
+	}
+
+	revenueType := "refund"
+	if request.Kind == "VOID" {
+		revenueType = "void"
+	}
+	counter := Income{
+		Type:           original.Type,
+		GuestID:        original.GuestID,
+		RoomNumber:     original.RoomNumber,
+		Amount:         request.Amount.Neg(),
+		Currency:       original.Currency,
+		OriginalAmount: original.originalShare(request.Amount).Neg(),
+		ExchangeRate:   original.ExchangeRate,
+		RevenueType:    revenueType,
+		PaymentMethod:  original.PaymentMethod,
+		ReversalOfID:   &original.ID,
+		CreatedAt:      time.Now().UTC(),
+	}
+	// The money is paid out at the desk by the receptionist who asked for the refund, so it comes out of
+	// their drawer whether it was processed there or approved by an admin
+	if err := attributeIncome(tx, &counter, request.RequestedBy); err != nil {
+		return err
+	}
+	if err := tx.Create(&counter).Error; err != nil {
+		return err
+	}
+	if err := postIncomeReversal(tx, original, counter); err != nil {
+		return err
+	}
+
+	var payment Payment
+	err := tx.Where("income_id = ?", original.ID).First(&payment).Error
+	if err == nil {
+		refund := Payment{
+			GuestID:        payment.GuestID,
+			ReservationID:  payment.ReservationID,
+			Amount:         request.Amount.Neg(),
+			Currency:       counter.Currency,
+			OriginalAmount: counter.OriginalAmount,
+			ExchangeRate:   counter.ExchangeRate,
+			Method:         payment.Method,
+			IncomeType:     payment.IncomeType,
+			IncomeID:       &counter.ID,
+			PaidAt:         counter.CreatedAt,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+		if err := syncPaymentTotals(tx, &refund); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if request.Kind == "VOID" {
+		if _, err := reverseLoyaltyForIncome(tx, original.ID); err != nil {
+			return err
+		}
+	} else {
+		var reversed Money
+		if err := tx.Model(&Income{}).
+			Where("reversal_of_id = ?", original.ID).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&reversed).Error; err != nil {
+			return err
+		}
+		if err := reverseLoyaltyShare(tx, original, reversed.Neg()); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	request.Status = "APPROVED"
+	request.CounterIncomeID = &counter.ID
+	request.DecidedBy = adminID
+	if adminID != nil {
+		request.DecidedAt = &now
+	}
+	return tx.Save(request).Error
+}
+
+// requestRefund handles refund and void requests made at the front desk
+func requestRefund(c *gin.Context, kind string) {
+	id := c.Param("id")
+
+	var body struct {
+		Amount Money
+		Reason string
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A reason is required"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	// Lock the income so two refunds taken at once can't both be checked against the same remaining amount
+	var income Income
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&income, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Income record not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch income record"})
+		return
+	}
+	if income.ReversalOfID != nil || income.Amount.Sign() <= 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Only positive income records can be refunded or voided"})
+		return
+	}
+
+	remaining, err := refundableAmount(tx, income)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check earlier refunds"})
+		return
+	}
+
+	// A void cancels the whole record, so it can only be used before anything has been refunded
+	if kind == "VOID" {
+		if remaining.Minor < income.Amount.Minor {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"message": "This income already has refunds against it; refund the rest instead"})
+			return
+		}
+		body.Amount = income.Amount
+	}
+	if body.Amount.Sign() <= 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Refund amount must be greater than zero"})
+		return
+	}
+	if body.Amount.Minor > remaining.Minor {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"message": fmt.Sprintf("Only %s of this income can still be refunded", remaining)})
+		return
+	}
+
+	request := RefundRequest{
+		IncomeID:    income.ID,
+		Kind:        kind,
+		Amount:      body.Amount,
+		Reason:      strings.TrimSpace(body.Reason),
+		Status:      "PENDING",
+		RequestedBy: receptionistID(c),
+	}
+	if err := tx.Create(&request).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create refund request"})
+		return
+	}
+
+	settings := loadRefundSettings(tx)
+	if request.Amount.Minor <= settings.ApprovalThreshold.Minor {
+		if err := processRefund(tx, &request, nil); err != nil {
+			tx.Rollback()
+			if errors.Is(err, ErrBusinessDateClosed) {
+				c.JSON(http.StatusConflict, gin.H{"message": "Business date is closed"})
+				return
+			}
+			if errors.Is(err, ErrNoOpenShift) {
+				c.JSON(http.StatusConflict, gin.H{"message": "Open a cashier shift before refunding cash"})
+				return
+			}
+			fmt.Printf("Error processing refund: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process refund"})
+			return
+		}
+	}
+	tx.Commit()
+
+	message := "Refund processed successfully"
+	if request.Status == "PENDING" {
+		message = "Refund is waiting for admin approval"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"refund":  request,
+	})
+}
+
+func RefundIncome(c *gin.Context) {
+	requestRefund(c, "REFUND")
+}
+
+func VoidIncome(c *gin.Context) {
+	requestRefund(c, "VOID")
+}
+
+func GetRefundRequests(c *gin.Context) {
+	query := DB.Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []RefundRequest
+	if err := query.Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch refund requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// loadPendingRefund fetches a refund request that is still waiting for a decision, locking it in tx
+func loadPendingRefund(c *gin.Context, tx *gorm.DB) (*RefundRequest, bool) {
+	var request RefundRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Refund request not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch refund request"})
+		return nil, false
+	}
+	if request.Status != "PENDING" {
+		c.JSON(http.StatusConflict, gin.H{"message": "Refund request is already " + strings.ToLower(request.Status)})
+		return nil, false
+	}
+	return &request, true
+}
+
+func ApproveRefund(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	tx := DB.Begin()
+	request, ok := loadPendingRefund(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	if err := processRefund(tx, request, &adminID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrBusinessDateClosed) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Business date is closed"})
+			return
+		}
+		if errors.Is(err, ErrNoOpenShift) {
+			c.JSON(http.StatusConflict, gin.H{"message": "The receptionist who asked for this refund must open a cashier shift to pay out cash"})
+			return
+		}
+		fmt.Printf("Error processing refund: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process refund"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund approved successfully",
+		"refund":  request,
+	})
+}
+
+func RejectRefund(c *gin.Context) {
+	adminID := c.GetInt("user_id")
+
+	var body struct {
+		Reason *string
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	tx := DB.Begin()
+	request, ok := loadPendingRefund(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	now := time.Now().UTC()
+	request.Status = "REJECTED"
+	request.DecidedBy = &adminID
+	request.DecidedAt = &now
+	request.RejectReason = body.Reason
+	if err := tx.Save(request).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reject refund"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund rejected",
+		"refund":  request,
+	})
+}
+
+func GetRefundSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, loadRefundSettings(DB))
+}
+
+func UpdateRefundSettings(c *gin.Context) {
+	var settings RefundSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if settings.ApprovalThreshold.Sign() < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Approval threshold can't be negative"})
+		return
+	}
+
+	existing := loadRefundSettings(DB)
+	settings.ID = existing.ID
+	if err := DB.Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update refund settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -9,21 +9,23 @@ import (
 )
 
 type Reservation struct {
-	ID              int       `gorm:"primaryKey;autoIncrement"`
-	Name            string    `gorm:"not null"`
-	NationalID      *string   `gorm:"null"`
-	Phone           *string   `gorm:"null"`
-	RoomType        string    `gorm:"type:enum('FULL-NIGHT','DAY-CAUTION','SESSION');default:'FULL-NIGHT';not null"`
-	GuestCount      int       `gorm:"not null"`
-	RoomCount       int       `gorm:"not null"`
-	CheckinDate     time.Time `gorm:"type:date;not null"`
-	CheckoutDate    time.Time `gorm:"type:date;not null"`
-	ReservationDate time.Time `gorm:"type:date;not null"`
-	Status          string    `gorm:"type:enum('CANCELLED','CHECKED-IN','CONFIRMED');default:'CONFIRMED'"`
-	ExtraBed        bool      `gorm:"default:false"`
-	PaymentType     string    `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');default:'NONE'"`
-	AmountPaid      *int      `gorm:"null"`
-	Notes           *string   `gorm:"type:text;null"`
+	ID              int                `gorm:"primaryKey;autoIncrement"`
+	Name            string             `gorm:"not null"`
+	NationalID      *string            `gorm:"null"`
+	Phone           *string            `gorm:"null"`
+	RoomType        string             `gorm:"type:enum('FULL-NIGHT','DAY-CAUTION','SESSION');default:'FULL-NIGHT';not null"`
+	GuestCount      int                `gorm:"not null"`
+	RoomCount       int                `gorm:"not null"`
+	CheckinDate     time.Time          `gorm:"type:date;not null"`
+	CheckoutDate    time.Time          `gorm:"type:date;not null"`
+	ReservationDate time.Time          `gorm:"type:date;not null"`
+	Status          string             `gorm:"type:enum('CANCELLED','CHECKED-IN','CONFIRMED');default:'CONFIRMED'"`
+	ExtraBed        bool               `gorm:"default:false"`
+	PaymentType     string             `gorm:"type:enum('NONE', 'KPAY', 'AYAPAY', 'WAVEPAY', 'CASH');default:'NONE'"`
+	AmountPaid      *Money             `gorm:"null"`
+	Notes           *string            `gorm:"type:text;null"`
+	ProfileID       *int               `gorm:"null;index"`
+	Documents       []IdentityDocument `gorm:"foreignKey:ReservationID"`
 }
 
 func CreateReservation(c *gin.Context) {
@@ -38,6 +40,16 @@ func CreateReservation(c *gin.Context) {
 		reservation.ReservationDate = time.Now().UTC()
 	}
 
+	// Documents are optional when booking, but any that are given must be valid
+	for i := range reservation.Documents {
+		reservation.Documents[i].ID = 0
+		reservation.Documents[i].ScanPath = nil
+		if err := validateDocument(&reservation.Documents[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
 	if err := DB.Create(&reservation).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create reservation: " + err.Error()})
 		return
@@ -142,7 +154,7 @@ func UpdateReservation(c *gin.Context) {
 		return
 	}
 
-	if err := DB.Model(&existingReservation).Updates(reservation).Error; err != nil {
+	if err := DB.Model(&existingReservation).Omit("Documents").Updates(reservation).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
@@ -16,7 +16,7 @@ type Staff struct {
 	Email    string `gorm:"not null"`
 	Username string `gorm:"unique; not null"`
 	Password string `gorm:"not null"`
-	Role     string `gorm:"type:enum('HOUSEKEEPING');default:'HOUSEKEEPING'"`
+	Role     string `gorm:"type:enum('HOUSEKEEPING','KITCHEN');default:'HOUSEKEEPING'"`
 }
 
 type CleaningRecord struct {
@@ -130,6 +130,21 @@ func StaffAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// StaffRoleMiddleware only lets staff with one of the given roles through; it runs after StaffAuthMiddleware
+func StaffRoleMiddleware(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"message": "Your role does not have access to this resource"})
+		c.Abort()
+	}
+}
+
 func GetRoomsForCleaning(c *gin.Context) {
 	staffId := c.GetFloat64("user_id")
 	if staffId == 0 {
@@ -0,0 +1,493 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// TaxSettings holds the hotel's service charge and commercial tax set-up; there is a single row, saved the
+// first time an admin changes the settings, and the defaults apply until then
+type TaxSettings struct {
+	ID                int       `json:"id" gorm:"primaryKey"`
+	Enabled           bool      `json:"enabled"`
+	CommercialTaxRate float64   `json:"commercialTaxRate"` // Percent, charged on the charge plus its service charge
+	ServiceChargeRate float64   `json:"serviceChargeRate"` // Percent of the charge
+	PricesIncludeTax  bool      `json:"pricesIncludeTax"`  // Room and menu prices already include service charge and tax
+	Rules             []TaxRule `json:"rules" gorm:"-"`
+}
+
+// TaxRule says whether a type of charge attracts service charge and commercial tax
+type TaxRule struct {
+	ChargeType    string `json:"chargeType" gorm:"primaryKey;type:varchar(20)"` // ROOM or FOOD
+	ServiceCharge bool   `json:"serviceCharge"`
+	CommercialTax bool   `json:"commercialTax"`
+}
+
+// taxChargeTypes are the charges tax is worked out on
+var taxChargeTypes = []string{"ROOM", "FOOD"}
+
+// TaxLine records how one charge, or a change to it, splits into the charge itself, service charge and
+// commercial tax. Invoices and the monthly tax report are built from these.
+type TaxLine struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	GuestID       *int      `gorm:"null;index"` // Empty for walk-in sales
+	ChargeType    string    `gorm:"type:varchar(20);not null"`
+	SourceType    string    `gorm:"type:varchar(30);not null"` // ROOM_CHARGE, FOOD_ORDER or GUEST for charges entered on the folio
+	SourceID      uint      `gorm:"not null"`
+	BusinessDate  time.Time `gorm:"type:date;not null;index"`
+	Net           Money     `gorm:"not null"` // The charge before service charge and tax
+	ServiceCharge Money     `gorm:"not null"`
+	CommercialTax Money     `gorm:"not null"`
+	Taxable       bool      `gorm:"not null"` // False when the charge type is exempt from commercial tax
+	Inclusive     bool      `gorm:"not null"` // Service charge and tax came out of the price rather than on top of it
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+// taxSplit is a charge broken into its net amount, service charge and commercial tax
+type taxSplit struct {
+	Net           Money
+	ServiceCharge Money
+	CommercialTax Money
+	Taxable       bool
+	Inclusive     bool
+	Recorded      bool // Worked out under enabled tax settings, so a TaxLine is kept for it
+}
+
+// untaxed is a charge that service charge and tax don't apply to
+func untaxed(amount Money) taxSplit {
+	return taxSplit{Net: amount}
+}
+
+// Gross is what the guest pays for the charge
+func (split taxSplit) Gross() Money {
+	return split.Net.Add(split.ServiceCharge).Add(split.CommercialTax)
+}
+
+// Extra is what is added on top of the charge itself; nothing when prices already include tax
+func (split taxSplit) Extra() Money {
+	if split.Inclusive {
+		return Money{Currency: split.Net.Currency}
+	}
+	return split.ServiceCharge.Add(split.CommercialTax)
+}
+
+// loadTaxSettings returns the stored tax settings and rules, or the defaults for any not saved yet. Tax
+// starts disabled so bills don't change until an admin sets it up.
+func loadTaxSettings(tx *gorm.DB) (TaxSettings, error) {
+	var settings TaxSettings
+	err := tx.First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		settings = TaxSettings{CommercialTaxRate: 5, ServiceChargeRate: 10}
+	} else if err != nil {
+		return settings, err
+	}
+
+	if err := tx.Find(&settings.Rules).Error; err != nil {
+		return settings, err
+	}
+	known := make(map[string]bool, len(settings.Rules))
+	for _, rule := range settings.Rules {
+		known[rule.ChargeType] = true
+	}
+	for _, chargeType := range taxChargeTypes {
+		if !known[chargeType] {
+			settings.Rules = append(settings.Rules, TaxRule{ChargeType: chargeType, ServiceCharge: true, CommercialTax: true})
+		}
+	}
+	return settings, nil
+}
+
+// rule returns the tax rule for a charge type; unknown types are exempt
+func (settings TaxSettings) rule(chargeType string) TaxRule {
+	for _, rule := range settings.Rules {
+		if rule.ChargeType == chargeType {
+			return rule
+		}
+	}
+	return TaxRule{ChargeType: chargeType}
+}
+
+// split works out the service charge and commercial tax on a charge. Service charge is a percentage of
+// the net charge and commercial tax a percentage of the net charge plus service charge. Both are rounded
+// to the whole kyat, halves away from zero, as the folio is kept in whole kyat. When prices include tax, the tax and then the
+// service charge are taken out of the amount and the net charge is what is left, so the parts always add
+// back up to the price exactly.
+func (settings TaxSettings) split(chargeType string, amount Money) taxSplit {
+	if !settings.Enabled {
+		return untaxed(amount)
+	}
+	rule := settings.rule(chargeType)
+
+	serviceRate, taxRate := 0.0, 0.0
+	if rule.ServiceCharge {
+		serviceRate = settings.ServiceChargeRate / 100
+	}
+	if rule.CommercialTax {
+		taxRate = settings.CommercialTaxRate / 100
+	}
+
+	split := taxSplit{Taxable: rule.CommercialTax, Inclusive: settings.PricesIncludeTax, Recorded: true}
+	if settings.PricesIncludeTax {
+		split.CommercialTax = amount.Mul(taxRate / (1 + taxRate)).Round()
+		split.ServiceCharge = amount.Sub(split.CommercialTax).Mul(serviceRate / (1 + serviceRate)).Round()
+		split.Net = amount.Sub(split.CommercialTax).Sub(split.ServiceCharge)
+	} else {
+		split.Net = amount
+		split.ServiceCharge = amount.Mul(serviceRate).Round()
+		split.CommercialTax = amount.Add(split.ServiceCharge).Mul(taxRate).Round()
+	}
+	return split
+}
+
+// recordedTaxShare splits taking part off a charge the way the charge was taxed when it was recorded, not
+// at the current rates, so it comes off with the service charge and tax that went on with it. lines
+// selects the charge's tax lines and whole is what the charge stood at before. The parts of the charge
+// recorded under different rules each come down in proportion, and whatever of the charge has no tax
+// line, such as charges posted while tax was disabled, comes off untaxed. The splits are negative.
+func recordedTaxShare(lines *gorm.DB, part, whole Money) ([]taxSplit, error) {
+	var groups []struct {
+		Taxable       bool
+		Inclusive     bool
+		Net           Money
+		ServiceCharge Money
+		CommercialTax Money
+	}
+	if err := lines.
+		Select("taxable, inclusive, COALESCE(SUM(net), 0) as net, COALESCE(SUM(service_charge), 0) as service_charge, COALESCE(SUM(commercial_tax), 0) as commercial_tax").
+		Group("taxable, inclusive").
+		Scan(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	type recordedPart struct {
+		split taxSplit
+		price Money // What the part adds to the charge: the net amount, or all of it when tax is inclusive
+	}
+	var parts []recordedPart
+	var recorded Money
+	for _, group := range groups {
+		split := taxSplit{
+			Net:           group.Net,
+			ServiceCharge: group.ServiceCharge,
+			CommercialTax: group.CommercialTax,
+			Taxable:       group.Taxable,
+			Inclusive:     group.Inclusive,
+			Recorded:      true,
+		}
+		price := split.Net
+		if split.Inclusive {
+			price = split.Gross()
+		}
+		if price.Sign() <= 0 {
+			continue
+		}
+		parts = append(parts, recordedPart{split, price})
+		recorded = recorded.Add(price)
+	}
+
+	var splits []taxSplit
+	var taken Money
+	for i, recordedPart := range parts {
+		share := part.Share(recordedPart.price, whole)
+		// With nothing left untaxed, the last part takes what rounding the shares left over
+		if i == len(parts)-1 && recorded.Minor >= whole.Minor {
+			share = part.Sub(taken)
+		}
+		share = minMoney(share, recordedPart.price)
+		taken = taken.Add(share)
+
+		split := recordedPart.split
+		serviceCharge := split.ServiceCharge.Share(share, recordedPart.price)
+		commercialTax := split.CommercialTax.Share(share, recordedPart.price)
+		net := share
+		if split.Inclusive {
+			net = share.Sub(serviceCharge).Sub(commercialTax)
+		}
+		split.Net, split.ServiceCharge, split.CommercialTax = net.Neg(), serviceCharge.Neg(), commercialTax.Neg()
+		splits = append(splits, split)
+	}
+	if rest := part.Sub(taken); !rest.IsZero() {
+		splits = append(splits, untaxed(rest.Neg()))
+	}
+	return splits, nil
+}
+
+// changeTaxSplits splits a change in a charge into its net amount, service charge and commercial tax: an
+// increase is taxed at the current rates, and a reduction takes off the tax recorded on the charge. lines
+// selects the charge's tax lines and before is what the charge stood at before the change.
+func changeTaxSplits(tx *gorm.DB, lines *gorm.DB, chargeType string, before, delta Money) ([]taxSplit, error) {
+	if delta.Sign() < 0 {
+		return recordedTaxShare(lines, delta.Neg(), before)
+	}
+	settings, err := loadTaxSettings(tx)
+	if err != nil {
+		return nil, err
+	}
+	return []taxSplit{settings.split(chargeType, delta)}, nil
+}
+
+// recordTax keeps the tax line for a charge and, when tax is added on top of prices, adds it to the
+// guest's folio. A zero businessDate means the current business date.
+func recordTax(tx *gorm.DB, guestID *int, chargeType, sourceType string, sourceID uint, businessDate time.Time, split taxSplit) error {
+	if !split.Recorded || (split.Net.IsZero() && split.Extra().IsZero()) {
+		return nil
+	}
+	if businessDate.IsZero() {
+		current, err := currentBusinessDate(tx)
+		if err != nil {
+			return err
+		}
+		businessDate = current
+	}
+
+	line := TaxLine{
+		GuestID:       guestID,
+		ChargeType:    chargeType,
+		SourceType:    sourceType,
+		SourceID:      sourceID,
+		BusinessDate:  businessDate,
+		Net:           split.Net,
+		ServiceCharge: split.ServiceCharge,
+		CommercialTax: split.CommercialTax,
+		Taxable:       split.Taxable,
+		Inclusive:     split.Inclusive,
+	}
+	if err := tx.Create(&line).Error; err != nil {
+		return err
+	}
+
+	if guestID == nil || split.Extra().IsZero() {
+		return nil
+	}
+	return tx.Model(&Guests{}).Where("id = ?", *guestID).Updates(map[string]interface{}{
+		"service_charges": gorm.Expr("service_charges + ?", split.ServiceCharge),
+		"tax_charges":     gorm.Expr("tax_charges + ?", split.CommercialTax),
+	}).Error
+}
+
+func GetTaxSettings(c *gin.Context) {
+	settings, err := loadTaxSettings(DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateTaxSettings changes the rates and rules; charges already posted keep the tax worked out on them
+func UpdateTaxSettings(c *gin.Context) {
+	var request TaxSettings
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if request.CommercialTaxRate < 0 || request.CommercialTaxRate > 100 || request.ServiceChargeRate < 0 || request.ServiceChargeRate > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Rates must be between 0 and 100 percent"})
+		return
+	}
+	for _, rule := range request.Rules {
+		known := false
+		for _, chargeType := range taxChargeTypes {
+			known = known || rule.ChargeType == chargeType
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown charge type " + rule.ChargeType})
+			return
+		}
+	}
+
+	tx := DB.Begin()
+	settings, err := loadTaxSettings(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax settings"})
+		return
+	}
+	settings.Enabled = request.Enabled
+	settings.CommercialTaxRate = request.CommercialTaxRate
+	settings.ServiceChargeRate = request.ServiceChargeRate
+	settings.PricesIncludeTax = request.PricesIncludeTax
+	if err := tx.Save(&settings).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save tax settings"})
+		return
+	}
+	// Save every rule, so the defaults for any left out are kept from now on
+	for i := range settings.Rules {
+		for _, rule := range request.Rules {
+			if rule.ChargeType == settings.Rules[i].ChargeType {
+				settings.Rules[i] = rule
+			}
+		}
+		if err := tx.Save(&settings.Rules[i]).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save tax rules"})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetGuestInvoice returns a stay's bill with service charge and commercial tax shown separately
+func GetGuestInvoice(c *gin.Context) {
+	id := c.Param("id")
+
+	var guest Guests
+	if err := DB.First(&guest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Guest not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch guest"})
+		return
+	}
+
+	var taxes []struct {
+		ChargeType    string
+		Inclusive     bool
+		ServiceCharge Money
+		CommercialTax Money
+	}
+	if err := DB.Model(&TaxLine{}).
+		Select("charge_type, inclusive, COALESCE(SUM(service_charge), 0) as service_charge, COALESCE(SUM(commercial_tax), 0) as commercial_tax").
+		Where("guest_id = ?", guest.ID).
+		Group("charge_type, inclusive").
+		Scan(&taxes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax lines"})
+		return
+	}
+
+	// Folio charges hold tax that was included in the price, so it is taken out to show the net charge
+	var serviceCharge, commercialTax Money
+	included := make(map[string]Money)
+	for _, tax := range taxes {
+		serviceCharge = serviceCharge.Add(tax.ServiceCharge)
+		commercialTax = commercialTax.Add(tax.CommercialTax)
+		if tax.Inclusive {
+			included[tax.ChargeType] = included[tax.ChargeType].Add(tax.ServiceCharge).Add(tax.CommercialTax)
+		}
+	}
+
+	type invoiceLine struct {
+		Description string `json:"description"`
+		Amount      Money  `json:"amount"`
+	}
+	lines := []invoiceLine{
+		{Description: "Room charges", Amount: guest.RoomCharges.Sub(included["ROOM"])},
+		{Description: "Food and beverage", Amount: guest.FoodCharges.Sub(included["FOOD"])},
+		{Description: "Other charges", Amount: guest.ExtraCharges},
+	}
+	var subtotal Money
+	for _, line := range lines {
+		subtotal = subtotal.Add(line.Amount)
+	}
+
+	charges, paid, err := stayBalance(DB, guest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate balance"})
+		return
+	}
+
+	settings, err := loadTaxSettings(DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"guestId":           guest.ID,
+		"guestName":         guest.Name,
+		"roomNumber":        guest.RoomNumber,
+		"checkinDate":       guest.CheckinDate,
+		"checkoutDate":      guest.CheckoutDate,
+		"lines":             lines,
+		"subtotal":          subtotal,
+		"serviceCharge":     serviceCharge,
+		"serviceChargeRate": settings.ServiceChargeRate,
+		"commercialTax":     commercialTax,
+		"commercialTaxRate": settings.CommercialTaxRate,
+		"discount":          guest.LoyaltyDiscount,
+		"total":             charges,
+		"paid":              paid,
+		"balance":           charges.Sub(paid),
+	})
+}
+
+// GetMonthlyTaxReport totals the service charge and commercial tax posted in a month, by charge type and
+// by day, for filing
+func GetMonthlyTaxReport(c *gin.Context) {
+	month := c.Param("month")
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid month format. Use YYYY-MM"})
+		return
+	}
+	end := start.AddDate(0, 1, -1)
+
+	selectTotals := `COALESCE(SUM(CASE WHEN taxable THEN net + service_charge ELSE 0 END), 0) as taxable_sales,
+		COALESCE(SUM(CASE WHEN taxable THEN 0 ELSE net + service_charge END), 0) as exempt_sales,
+		COALESCE(SUM(service_charge), 0) as service_charge,
+		COALESCE(SUM(commercial_tax), 0) as commercial_tax`
+	period := DB.Model(&TaxLine{}).Where("business_date BETWEEN ? AND ?", start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	var byType []struct {
+		ChargeType    string `json:"chargeType"`
+		TaxableSales  Money  `json:"taxableSales"` // Net charges plus service charge that commercial tax was charged on
+		ExemptSales   Money  `json:"exemptSales"`
+		ServiceCharge Money  `json:"serviceCharge"`
+		CommercialTax Money  `json:"commercialTax"`
+	}
+	if err := period.Session(&gorm.Session{}).
+		Select("charge_type, " + selectTotals).
+		Group("charge_type").
+		Order("charge_type").
+		Scan(&byType).Error; err != nil {
+		fmt.Printf("Error building tax report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build tax report"})
+		return
+	}
+
+	var byDay []struct {
+		Date          string `json:"date"`
+		TaxableSales  Money  `json:"taxableSales"`
+		ExemptSales   Money  `json:"exemptSales"`
+		ServiceCharge Money  `json:"serviceCharge"`
+		CommercialTax Money  `json:"commercialTax"`
+	}
+	if err := period.Session(&gorm.Session{}).
+		Select("DATE_FORMAT(business_date, '%Y-%m-%d') as date, " + selectTotals).
+		Group("business_date").
+		Order("business_date").
+		Scan(&byDay).Error; err != nil {
+		fmt.Printf("Error building tax report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build tax report"})
+		return
+	}
+
+	var taxableSales, exemptSales, serviceCharge, commercialTax Money
+	for _, line := range byType {
+		taxableSales = taxableSales.Add(line.TaxableSales)
+		exemptSales = exemptSales.Add(line.ExemptSales)
+		serviceCharge = serviceCharge.Add(line.ServiceCharge)
+		commercialTax = commercialTax.Add(line.CommercialTax)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":  month,
+		"start":  start.Format("2006-01-02"),
+		"end":    end.Format("2006-01-02"),
+		"byType": byType,
+		"byDay":  byDay,
+		"total": gin.H{
+			"taxableSales":  taxableSales,
+			"exemptSales":   exemptSales,
+			"serviceCharge": serviceCharge,
+			"commercialTax": commercialTax,
+		},
+	})
+}
@@ -0,0 +1,258 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// paymentMethods are the ways money can be taken at the counter
+var paymentMethods = map[string]bool{
+	"CASH":    true,
+	"KPAY":    true,
+	"AYAPAY":  true,
+	"WAVEPAY": true,
+}
+
+type walkInOrderRequest struct {
+	CustomerName  *string
+	PaymentMethod string
+	OrderTime     time.Time
+	Notes         *string
+	Items         []foodOrderItemRequest
+}
+
+// foodRevenueBreakdown is food revenue for a period split by where the order came from
+type foodRevenueBreakdown struct {
+	Date        string `json:"date,omitempty"`
+	RoomService Money  `json:"roomService"`
+	WalkIn      Money  `json:"walkIn"`
+	Total       Money  `json:"total"`
+}
+
+// foodRevenueByType totals the orders placed between start and end, inclusive, per day and order type,
+// straight from the orders rather than the rollup
+func foodRevenueByType(db *gorm.DB, start, end string) ([]foodRevenueBreakdown, error) {
+	var days []foodRevenueBreakdown
+	err := db.Model(&FoodOrder{}).
+		Select(`DATE_FORMAT(DATE(order_time), '%Y-%m-%d') as date,
+			COALESCE(SUM(CASE WHEN order_type = 'ROOM_SERVICE' THEN total ELSE 0 END), 0) as room_service,
+			COALESCE(SUM(CASE WHEN order_type = 'WALK_IN' THEN total ELSE 0 END), 0) as walk_in,
+			COALESCE(SUM(total), 0) as total`).
+		Where("DATE(order_time) BETWEEN ? AND ? AND status <> ?", start, end, "CANCELLED").
+		Group("DATE(order_time)").
+		Order("DATE(order_time)").
+		Scan(&days).Error
+	return days, err
+}
+
+// CreateWalkInOrder records a restaurant sale to a customer who isn't staying at the hotel. They pay straight
+// away, so the order and the income row for the payment are created together.
+func CreateWalkInOrder(c *gin.Context) {
+	var request walkInOrderRequest
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !paymentMethods[request.PaymentMethod] {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Payment method must be one of CASH, KPAY, AYAPAY or WAVEPAY"})
+		return
+	}
+
+	tx := DB.Begin()
+
+	items, total, err := buildOrderItems(tx, request.Items)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Service charge and tax are paid with the order when prices don't already include them
+	taxSettings, err := loadTaxSettings(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tax settings"})
+		return
+	}
+	split := taxSettings.split("FOOD", total)
+	income := Income{
+		Type:          "food",
+		Amount:        total.Add(split.Extra()),
+		RevenueType:   "revenue",
+		PaymentMethod: request.PaymentMethod,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := attributeIncome(tx, &income, receptionistID(c)); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to find cashier shift")
+		return
+	}
+	if err := tx.Create(&income).Error; err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to record payment")
+		return
+	}
+	if err := postSaleJournal(tx, income, accountFoodRevenue, split); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to post payment to the ledger")
+		return
+	}
+
+	paymentMethod := request.PaymentMethod
+	order := FoodOrder{
+		OrderType:     "WALK_IN",
+		CustomerName:  request.CustomerName,
+		PaymentMethod: &paymentMethod,
+		IncomeID:      &income.ID,
+		OrderTime:     request.OrderTime,
+		Notes:         request.Notes,
+		Total:         total,
+		Status:        "PLACED",
+		Items:         items,
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to create food order: "+err.Error())
+		return
+	}
+	if err := postFoodRevenue(tx, &order, order.Total); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to update food revenue")
+		return
+	}
+	if err := recordTax(tx, nil, "FOOD", "FOOD_ORDER", order.ID, time.Time{}, split); err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to record tax")
+		return
+	}
+	income.FoodOrderID = &order.ID
+	if err := tx.Model(&income).Update("food_order_id", order.ID).Error; err != nil {
+		tx.Rollback()
+		respondFoodOrderError(c, err, "Failed to record payment")
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Walk-in order created successfully",
+		"order":   order,
+		"income":  income,
+	})
+}
+
+// settleWalkInChange takes or gives back the difference when a paid walk-in order changes. The income rows
+// already posted are left as they were taken, so closed shifts keep their takings: more money is a new
+// income row, and money given back is a counter-entry against the order's earlier income, newest first,
+// each up to what hasn't already been refunded from it. delta is the change in the order's total and
+// splits its service charge and tax; takenBy is the receptionist handling the money, on their own shift.
+func settleWalkInChange(tx *gorm.DB, order *FoodOrder, delta Money, splits []taxSplit, takenBy *int) error {
+	var original Income
+	if err := tx.First(&original, *order.IncomeID).Error; err != nil {
+		return err
+	}
+
+	amount := delta
+	for _, split := range splits {
+		amount = amount.Add(split.Extra())
+	}
+	if amount.Sign() > 0 {
+		income := Income{
+			Type:          "food",
+			Amount:        amount,
+			RevenueType:   "revenue",
+			PaymentMethod: original.PaymentMethod,
+			FoodOrderID:   &order.ID,
+			CreatedAt:     time.Now().UTC(),
+		}
+		if err := attributeIncome(tx, &income, takenBy); err != nil {
+			return err
+		}
+		if err := tx.Create(&income).Error; err != nil {
+			return err
+		}
+		for _, split := range splits {
+			if err := postSaleJournal(tx, income, accountFoodRevenue, split); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var incomes []Income
+	if err := tx.Where("(id = ? OR food_order_id = ?) AND reversal_of_id IS NULL", original.ID, order.ID).
+		Order("id DESC").
+		Find(&incomes).Error; err != nil {
+		return err
+	}
+	owed := amount.Neg()
+	for _, income := range incomes {
+		if owed.Sign() <= 0 {
+			break
+		}
+		remaining, err := refundableAmount(tx, income)
+		if err != nil {
+			return err
+		}
+		back := minMoney(owed, remaining)
+		if back.Sign() <= 0 {
+			continue
+		}
+		counter := Income{
+			Type:          income.Type,
+			Amount:        back.Neg(),
+			RevenueType:   "refund",
+			PaymentMethod: income.PaymentMethod,
+			ReversalOfID:  &income.ID,
+			FoodOrderID:   &order.ID,
+			CreatedAt:     time.Now().UTC(),
+		}
+		if err := attributeIncome(tx, &counter, takenBy); err != nil {
+			return err
+		}
+		if err := tx.Create(&counter).Error; err != nil {
+			return err
+		}
+		if err := postIncomeReversal(tx, income, counter); err != nil {
+			return err
+		}
+		owed = owed.Sub(back)
+	}
+	return nil
+}
+
+// GetFoodRevenueByType reports room-service and walk-in food revenue for each day in a range
+func GetFoodRevenueByType(c *gin.Context) {
+	start := c.Param("start")
+	end := c.Param("end")
+
+	if _, err := time.Parse("2006-01-02", start); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start date format. Use YYYY-MM-DD"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", end); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	days, err := rolledUpFoodRevenue(DB, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate food revenue"})
+		return
+	}
+
+	totals := foodRevenueBreakdown{}
+	for _, day := range days {
+		totals.RoomService = totals.RoomService.Add(day.RoomService)
+		totals.WalkIn = totals.WalkIn.Add(day.WalkIn)
+		totals.Total = totals.Total.Add(day.Total)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":   days,
+		"totals": totals,
+	})
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -44,13 +45,34 @@ func HandleWebsiteBooking(c *gin.Context) {
 		Notes:           &booking.Notes,
 	}
 
-	if err := DB.Create(&reservation).Error; err != nil {
+	tx := DB.Begin()
+
+	profile, err := findOrCreateProfile(tx, booking.Name, &booking.NationalID, &booking.Phone)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrGuestBlacklisted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "We are unable to accept this booking online. Please contact the hotel."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create reservation",
+			"details": err.Error(),
+		})
+		return
+	}
+	if profile != nil {
+		reservation.ProfileID = &profile.ID
+	}
+
+	if err := tx.Create(&reservation).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create reservation",
 			"details": err.Error(),
 		})
 		return
 	}
+	tx.Commit()
 
 	// Send confirmation email (implement later)
 	// sendConfirmationEmail(booking)